@@ -0,0 +1,98 @@
+// Package cdc implements content-defined chunking: splitting a file into
+// variable-length chunks at boundaries chosen by the file's own content
+// (via a rolling hash) rather than fixed offsets. A small edit then only
+// reshuffles the chunks touching it instead of every chunk downstream of
+// the edit, which is what lets a fuzzy, per-chunk comparison recognize a
+// re-encoded or lightly-edited file as "the same" when a whole-file hash
+// would not.
+package cdc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+const (
+	// windowSize is the size of the rolling hash's sliding window.
+	windowSize = 48
+
+	// maskBits sizes chunks to ~8 KiB on average: a cut point occurs when
+	// the low maskBits bits of the rolling hash equal cutValue, which a
+	// uniformly distributed hash satisfies with probability 1/2^maskBits
+	// per byte.
+	maskBits = 13
+	mask     = 1<<maskBits - 1
+	cutValue = 0
+
+	minChunkSize = 2 * 1024
+	maxChunkSize = 64 * 1024
+
+	// polyBase is the rolling hash's polynomial multiplier (the FNV
+	// offset prime doubles as a convenient odd, high-entropy constant).
+	polyBase = uint64(1099511628211)
+)
+
+// outTable[b] precomputes b * polyBase^(windowSize-1), the contribution a
+// byte makes to the rolling hash by the time it's windowSize bytes old and
+// needs subtracting back out.
+var outTable [256]uint64
+
+func init() {
+	pow := uint64(1)
+	for i := 0; i < windowSize-1; i++ {
+		pow *= polyBase
+	}
+	for b := 0; b < 256; b++ {
+		outTable[b] = uint64(b) * pow
+	}
+}
+
+// Chunks splits r's content into variable-length chunks and returns the
+// hex SHA-256 of each chunk, in order.
+func Chunks(r io.Reader) ([]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return chunkHashes(b), nil
+}
+
+func chunkHashes(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var hashes []string
+	var window [windowSize]byte
+	var hash uint64
+	start := 0
+
+	for i, c := range data {
+		pos := i - start
+		if pos >= windowSize {
+			hash -= outTable[window[pos%windowSize]]
+		}
+		hash = hash*polyBase + uint64(c)
+		window[pos%windowSize] = c
+
+		size := pos + 1
+		if size < minChunkSize {
+			continue
+		}
+		if size >= maxChunkSize || (size >= windowSize && hash&mask == cutValue) {
+			hashes = append(hashes, sumHex(data[start:i+1]))
+			start = i + 1
+			hash = 0
+		}
+	}
+	if start < len(data) {
+		hashes = append(hashes, sumHex(data[start:]))
+	}
+	return hashes
+}
+
+func sumHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,76 @@
+package cdc
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestChunks_Empty(t *testing.T) {
+	hashes, err := Chunks(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("Chunks: %v", err)
+	}
+	if len(hashes) != 0 {
+		t.Errorf("Chunks(empty) = %v, want no chunks", hashes)
+	}
+}
+
+func TestChunks_Deterministic(t *testing.T) {
+	data := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog, "), 2000)
+
+	a, err := Chunks(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Chunks: %v", err)
+	}
+	b, err := Chunks(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Chunks: %v", err)
+	}
+	if len(a) != len(b) {
+		t.Fatalf("chunk counts differ across identical runs: %d vs %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("chunk %d differs across identical runs: %s vs %s", i, a[i], b[i])
+		}
+	}
+	if len(a) < 2 {
+		t.Fatalf("expected more than one chunk over %d bytes, got %d", len(data), len(a))
+	}
+}
+
+// TestChunks_EditLocality is the whole point of content-defined chunking:
+// an edit in the middle of the data should only change the chunk(s)
+// touching it, leaving chunks before and after untouched, unlike
+// fixed-size chunking where every chunk downstream of the edit shifts.
+func TestChunks_EditLocality(t *testing.T) {
+	base := make([]byte, 512*1024)
+	rand.New(rand.NewSource(1)).Read(base)
+
+	before, err := Chunks(bytes.NewReader(base))
+	if err != nil {
+		t.Fatalf("Chunks: %v", err)
+	}
+	if len(before) < 4 {
+		t.Fatalf("need at least 4 chunks to test locality, got %d", len(before))
+	}
+
+	edited := make([]byte, len(base))
+	copy(edited, base)
+	mid := len(edited) / 2
+	edited[mid] ^= 0xFF
+
+	after, err := Chunks(bytes.NewReader(edited))
+	if err != nil {
+		t.Fatalf("Chunks: %v", err)
+	}
+
+	if before[0] != after[0] {
+		t.Errorf("first chunk changed from an edit in the middle of the file: %s vs %s", before[0], after[0])
+	}
+	if before[len(before)-1] != after[len(after)-1] {
+		t.Errorf("last chunk changed from an edit in the middle of the file: %s vs %s", before[len(before)-1], after[len(after)-1])
+	}
+}
@@ -0,0 +1,175 @@
+package chart
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/saintfish/chardet"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/unicode/utf32"
+	"golang.org/x/text/transform"
+)
+
+const bmsBufferSize = 128 * 1024
+
+var (
+	reBMSTitle     = regexp.MustCompile(`(?i)^#title[\s\t]*(.*?)(?:\r\n|\r|\n|$)`)
+	reBMSSubtitle  = regexp.MustCompile(`(?i)^#subtitle[\s\t]*(.*?)(?:\r\n|\r|\n|$)`)
+	reBMSArtist    = regexp.MustCompile(`(?i)^#artist[\s\t]*(.*?)(?:\r\n|\r|\n|$)`)
+	reBMSSubartist = regexp.MustCompile(`(?i)^#subartist[\s\t]*(.*?)(?:\r\n|\r|\n|$)`)
+	reBMSWAV       = regexp.MustCompile(`#WAV([0-9A-Za-z]{2})\s(.*)`)
+)
+
+func init() {
+	p := BMSParser{}
+	Register(".bms", p)
+	Register(".bme", p)
+	Register(".bml", p)
+	Register(".pms", p)
+}
+
+// BMSParser parses the classic line-based BMS family of formats
+// (.bms/.bme/.bml/.pms), converting from their usual Shift_JIS (or other
+// legacy) encoding to UTF-8 before scanning.
+type BMSParser struct{}
+
+func (BMSParser) Parse(path string) (*Chart, error) {
+	raw, b, err := readBMSFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Chart{
+		Path: path,
+		Hash: calculateHash(b),
+		// MD5 must be computed from the raw, pre-transcode bytes: the
+		// bmsmd5 convention the wider ecosystem relies on is defined over
+		// the file as it sits on disk, not over our UTF-8 conversion of it.
+		MD5: calculateMD5(raw),
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	buf := make([]byte, 0, bmsBufferSize)
+	scanner.Buffer(buf, bmsBufferSize)
+	for scanner.Scan() {
+		if c.Artist != "" && c.Subartist != "" && c.Title != "" && c.Subtitle != "" {
+			break
+		}
+		line := scanner.Text()
+		if match := reBMSTitle.FindStringSubmatch(line); len(match) > 1 {
+			if c.Title == "" {
+				c.Title = match[1]
+			}
+			continue
+		}
+		if match := reBMSSubtitle.FindStringSubmatch(line); len(match) > 1 {
+			if c.Subtitle == "" {
+				c.Subtitle = match[1]
+			}
+			continue
+		}
+		if match := reBMSArtist.FindStringSubmatch(line); len(match) > 1 {
+			if c.Artist == "" {
+				c.Artist = match[1]
+			}
+			continue
+		}
+		if match := reBMSSubartist.FindStringSubmatch(line); len(match) > 1 {
+			if c.Subartist == "" {
+				c.Subartist = match[1]
+			}
+			continue
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("Error scanning file: %w", err)
+	}
+
+	return c, nil
+}
+
+// Keysounds scans the file's #WAVxx definitions. It intentionally does not
+// decode the file's encoding first: the filenames referenced by #WAV lines
+// are matched as raw bytes, matching the behavior callers have relied on
+// for locating the referenced files on disk.
+func (BMSParser) Keysounds(path string) ([]Keysound, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	keysounds := make([]Keysound, 0, 100)
+	for scanner.Scan() {
+		match := reBMSWAV.FindStringSubmatch(scanner.Text())
+		if len(match) == 0 {
+			continue
+		}
+		keysounds = append(keysounds, Keysound{Label: match[1], File: match[2]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error scanning file: %w", err)
+	}
+
+	return keysounds, nil
+}
+
+// readBMSFile returns both the raw bytes read from path and those bytes
+// converted to UTF-8. Callers that need the canonical bmsmd5 must hash the
+// raw bytes, not the converted ones.
+func readBMSFile(path string) (raw []byte, converted []byte, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error opening file: %w", err)
+	}
+	defer file.Close()
+
+	raw, err = io.ReadAll(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading file: %w", err)
+	}
+
+	converted, err = convertToUTF8(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error file: %s: %w", path, err)
+	}
+	return raw, converted, nil
+}
+
+func convertToUTF8(input []byte) ([]byte, error) {
+	d := chardet.NewTextDetector()
+	res, err := d.DetectBest(input)
+	if err != nil {
+		return nil, fmt.Errorf("Error detecting encoding: %w", err)
+	}
+
+	dec := japanese.ShiftJIS.NewDecoder()
+	if res.Confidence == 100 {
+		switch res.Charset {
+		case "UTF-8":
+			// No conversion needed
+			return input, nil
+		case "UTF-32BE":
+			dec = utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM).NewDecoder()
+		case "UTF-32LE":
+			dec = utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM).NewDecoder()
+		case "EUC-KR":
+			dec = korean.EUCKR.NewDecoder()
+		default:
+			if res.Charset != "Shift_JIS" {
+				fmt.Println("Unknown encoding:", res.Charset)
+			}
+		}
+	}
+	output, err := io.ReadAll(transform.NewReader(bytes.NewReader(input), dec))
+	if err != nil {
+		return nil, fmt.Errorf("Error converting to UTF-8: %w", err)
+	}
+	return output, nil
+}
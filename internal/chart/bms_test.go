@@ -0,0 +1,38 @@
+package chart
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// TestBMSParser_Parse_MD5MatchesRawBytes pins down bmsmd5 compatibility:
+// MD5 must be the checksum of the file exactly as it sits on disk, not of
+// this package's UTF-8 conversion of it.
+func TestBMSParser_Parse_MD5MatchesRawBytes(t *testing.T) {
+	sjis, err := japanese.ShiftJIS.NewEncoder().String("#TITLE テスト\r\n")
+	if err != nil {
+		t.Fatalf("encode fixture as Shift_JIS: %v", err)
+	}
+	raw := []byte(sjis)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.bms")
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	c, err := (BMSParser{}).Parse(path)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := md5.Sum(raw)
+	if got := hex.EncodeToString(want[:]); c.MD5 != got {
+		t.Errorf("MD5 = %s, want %s (bmsmd5 of the raw, pre-transcode bytes)", c.MD5, got)
+	}
+}
@@ -0,0 +1,105 @@
+package chart
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func init() {
+	Register(".bmson", BMSONParser{})
+}
+
+// BMSONParser parses the JSON-based .bmson chart format.
+type BMSONParser struct{}
+
+type bmsonInfo struct {
+	Title          string   `json:"title"`
+	Subtitle       string   `json:"subtitle"`
+	Artist         string   `json:"artist"`
+	SubtitleArtist string   `json:"subtitle_artist"`
+	Subartists     []string `json:"subartists"`
+	Genre          string   `json:"genre"`
+	ModeHint       string   `json:"mode_hint"`
+	InitBPM        float64  `json:"init_bpm"`
+	Level          int      `json:"level"`
+	Total          float64  `json:"total"`
+	ChartName      string   `json:"chart_name"`
+}
+
+type bmsonSoundChannel struct {
+	Name string `json:"name"`
+}
+
+type bmsonDocument struct {
+	Info          bmsonInfo           `json:"info"`
+	SoundChannels []bmsonSoundChannel `json:"sound_channels"`
+}
+
+func (BMSONParser) Parse(path string) (*Chart, error) {
+	b, doc, err := readBMSON(path)
+	if err != nil {
+		return nil, err
+	}
+
+	subartist := doc.Info.SubtitleArtist
+	if subartist == "" && len(doc.Info.Subartists) > 0 {
+		subartist = strings.Join(doc.Info.Subartists, ", ")
+	}
+
+	return &Chart{
+		Path:      path,
+		Hash:      calculateHash(b),
+		MD5:       calculateMD5(b),
+		Title:     doc.Info.Title,
+		Subtitle:  doc.Info.Subtitle,
+		Artist:    doc.Info.Artist,
+		Subartist: subartist,
+		Genre:     doc.Info.Genre,
+		ModeHint:  doc.Info.ModeHint,
+		InitBPM:   doc.Info.InitBPM,
+		Level:     doc.Info.Level,
+		Total:     doc.Info.Total,
+		ChartName: doc.Info.ChartName,
+	}, nil
+}
+
+// Keysounds walks sound_channels[].name, which bmson uses in place of the
+// #WAVxx label/filename pairs of the classic formats. Channels have no
+// label of their own, so Keysound.Label is left empty.
+func (BMSONParser) Keysounds(path string) ([]Keysound, error) {
+	_, doc, err := readBMSON(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keysounds := make([]Keysound, 0, len(doc.SoundChannels))
+	for _, ch := range doc.SoundChannels {
+		if ch.Name == "" {
+			continue
+		}
+		keysounds = append(keysounds, Keysound{File: ch.Name})
+	}
+	return keysounds, nil
+}
+
+func readBMSON(path string) ([]byte, *bmsonDocument, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error opening file: %w", err)
+	}
+	defer file.Close()
+
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading file: %w", err)
+	}
+
+	var doc bmsonDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, nil, fmt.Errorf("Error parsing JSON: %w", err)
+	}
+	return b, &doc, nil
+}
@@ -0,0 +1,109 @@
+package chart
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const bmsonFixture = `{
+	"info": {
+		"title": "Test Song",
+		"subtitle": "Test Subtitle",
+		"artist": "Test Artist",
+		"subartists": ["Guitar: A", "Vocal: B"],
+		"genre": "Test Genre",
+		"mode_hint": "beat-7k",
+		"init_bpm": 150,
+		"level": 7,
+		"total": 280.5,
+		"chart_name": "ANOTHER"
+	},
+	"sound_channels": [
+		{"name": "wav01.ogg"},
+		{"name": ""},
+		{"name": "wav02.ogg"}
+	]
+}`
+
+func writeBMSONFixture(t *testing.T, body string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.bmson")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+// TestBMSONParser_Parse_SubartistJoinsSubartists verifies that Subartist
+// falls back to joining info.subartists when subtitle_artist is absent,
+// matching the bmson spec's "subartists is the general case" convention.
+func TestBMSONParser_Parse_SubartistJoinsSubartists(t *testing.T) {
+	path := writeBMSONFixture(t, bmsonFixture)
+
+	c, err := (BMSONParser{}).Parse(path)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if want := "Guitar: A, Vocal: B"; c.Subartist != want {
+		t.Errorf("Subartist = %q, want %q", c.Subartist, want)
+	}
+	if c.Title != "Test Song" || c.Subtitle != "Test Subtitle" || c.Artist != "Test Artist" {
+		t.Errorf("info fields not mapped: %+v", c)
+	}
+	if c.Genre != "Test Genre" || c.ModeHint != "beat-7k" || c.ChartName != "ANOTHER" {
+		t.Errorf("info fields not mapped: %+v", c)
+	}
+	if c.InitBPM != 150 || c.Level != 7 || c.Total != 280.5 {
+		t.Errorf("info fields not mapped: %+v", c)
+	}
+}
+
+// TestBMSONParser_Parse_SubtitleArtistTakesPriority checks that an
+// explicit subtitle_artist wins over joining subartists, since it's the
+// more specific of the two fields.
+func TestBMSONParser_Parse_SubtitleArtistTakesPriority(t *testing.T) {
+	path := writeBMSONFixture(t, `{
+		"info": {
+			"title": "Test Song",
+			"subtitle_artist": "feat. Someone",
+			"subartists": ["Guitar: A"]
+		},
+		"sound_channels": []
+	}`)
+
+	c, err := (BMSONParser{}).Parse(path)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if want := "feat. Someone"; c.Subartist != want {
+		t.Errorf("Subartist = %q, want %q", c.Subartist, want)
+	}
+}
+
+// TestBMSONParser_Keysounds_SkipsEmptyChannelNames exercises the
+// sound_channels[].name walk, including the case where bmson track data
+// leaves a channel's name blank.
+func TestBMSONParser_Keysounds_SkipsEmptyChannelNames(t *testing.T) {
+	path := writeBMSONFixture(t, bmsonFixture)
+
+	keysounds, err := (BMSONParser{}).Keysounds(path)
+	if err != nil {
+		t.Fatalf("Keysounds: %v", err)
+	}
+
+	if len(keysounds) != 2 {
+		t.Fatalf("Keysounds = %+v, want 2 entries", keysounds)
+	}
+	for _, ks := range keysounds {
+		if ks.Label != "" {
+			t.Errorf("Label = %q, want empty (bmson channels have no label)", ks.Label)
+		}
+	}
+	if keysounds[0].File != "wav01.ogg" || keysounds[1].File != "wav02.ogg" {
+		t.Errorf("keysounds = %+v, want wav01.ogg then wav02.ogg", keysounds)
+	}
+}
@@ -0,0 +1,52 @@
+package chart
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParse_DispatchesByExtension proves that Parse/ForExt route a .bms and
+// a .bmson file to their respective registered Parser, not just that each
+// Parser works in isolation.
+func TestParse_DispatchesByExtension(t *testing.T) {
+	dir := t.TempDir()
+
+	bmsPath := filepath.Join(dir, "test.bms")
+	if err := os.WriteFile(bmsPath, []byte("#TITLE BMS Song\r\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	bmsonPath := filepath.Join(dir, "test.bmson")
+	if err := os.WriteFile(bmsonPath, []byte(bmsonFixture), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	bms, err := Parse(bmsPath)
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", bmsPath, err)
+	}
+	if _, ok := ForPath(bmsPath); !ok {
+		t.Errorf("ForPath(%s) = not found, want the BMSParser", bmsPath)
+	}
+	if bms.Title != "BMS Song" {
+		t.Errorf("Parse(%s).Title = %q, want %q", bmsPath, bms.Title, "BMS Song")
+	}
+
+	bmson, err := Parse(bmsonPath)
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", bmsonPath, err)
+	}
+	if _, ok := ForPath(bmsonPath); !ok {
+		t.Errorf("ForPath(%s) = not found, want the BMSONParser", bmsonPath)
+	}
+	if bmson.Title != "Test Song" {
+		t.Errorf("Parse(%s).Title = %q, want %q", bmsonPath, bmson.Title, "Test Song")
+	}
+
+	if _, ok := ForExt(".txt"); ok {
+		t.Errorf("ForExt(.txt) = found, want no parser registered")
+	}
+	if _, err := Parse(filepath.Join(dir, "test.txt")); err == nil {
+		t.Error("Parse with an unregistered extension should return an error")
+	}
+}
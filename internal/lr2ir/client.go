@@ -0,0 +1,139 @@
+// Package lr2ir looks up ranked chart info from the LR2IR (BMS ranking
+// site) search page by md5, caching results so repeat lookups don't hit
+// the network.
+package lr2ir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const searchURL = "http://www.dream-pro.info/~lavalse/LR2IR/search.cgi"
+
+// ErrOffline is returned by Client.Lookup when the client was constructed
+// with offline mode and the md5 isn't already cached.
+var ErrOffline = errors.New("lr2ir: not in cache and offline mode is enabled")
+
+// Result is the ranked chart info registered on LR2IR for a given bmsmd5.
+type Result struct {
+	MD5       string
+	Title     string
+	Artist    string
+	Players   int
+	Clears    int
+	FetchedAt time.Time
+}
+
+// Cache stores and retrieves Results keyed by md5.
+type Cache interface {
+	// Get returns the cached Result for md5, if any and not older than ttl.
+	// A zero ttl means cached entries never expire.
+	Get(ctx context.Context, md5 string, ttl time.Duration) (*Result, bool, error)
+	Put(ctx context.Context, result *Result) error
+}
+
+// Client fetches and caches LR2IR search results.
+type Client struct {
+	httpClient *http.Client
+	cache      Cache
+	limiter    *rate.Limiter
+	ttl        time.Duration
+	offline    bool
+}
+
+// NewClient returns a Client that looks up results through cache, rate
+// limiting live fetches to qps requests per second (qps <= 0 defaults to
+// 1). When offline is true, Lookup only ever consults the cache.
+func NewClient(cache Cache, qps float64, ttl time.Duration, offline bool) *Client {
+	if qps <= 0 {
+		qps = 1
+	}
+	return &Client{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		cache:      cache,
+		limiter:    rate.NewLimiter(rate.Limit(qps), 1),
+		ttl:        ttl,
+		offline:    offline,
+	}
+}
+
+// Lookup returns the LR2IR Result for md5, preferring the cache. When the
+// cache misses and the client isn't offline, it fetches the search page,
+// rate limited to the configured QPS, and caches the result.
+func (c *Client) Lookup(ctx context.Context, md5 string) (*Result, error) {
+	if result, ok, err := c.cache.Get(ctx, md5, c.ttl); err != nil {
+		return nil, err
+	} else if ok {
+		return result, nil
+	}
+
+	if c.offline {
+		return nil, ErrOffline
+	}
+
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	result, err := c.fetch(ctx, md5)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cache.Put(ctx, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *Client) fetch(ctx context.Context, md5 string) (*Result, error) {
+	u, err := url.Parse(searchURL)
+	if err != nil {
+		return nil, fmt.Errorf("lr2ir: parsing search URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("bmsmd5", md5)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("lr2ir: building request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("lr2ir: fetching search page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lr2ir: unexpected status %s", resp.Status)
+	}
+
+	result, err := parseSearchPage(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	result.MD5 = md5
+	result.FetchedAt = time.Now()
+	return result, nil
+}
+
+// SearchURL returns the human-facing LR2IR search URL for md5, for
+// printing alongside a Lookup result or as a fallback when the lookup
+// fails.
+func SearchURL(md5 string) string {
+	u, err := url.Parse(searchURL)
+	if err != nil {
+		panic(err)
+	}
+	q := u.Query()
+	q.Set("bmsmd5", md5)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
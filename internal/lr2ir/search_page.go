@@ -0,0 +1,40 @@
+package lr2ir
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// The search page is old, hand-rolled HTML with no stable structure to
+// speak of; these patterns pull out the handful of fields we care about
+// and simply leave a field blank/zero when a page doesn't match, rather
+// than failing the whole lookup.
+var (
+	reTitle   = regexp.MustCompile(`(?is)class="?title"?[^>]*>([^<]+)</`)
+	reArtist  = regexp.MustCompile(`(?is)class="?artist"?[^>]*>([^<]+)</`)
+	rePlayers = regexp.MustCompile(`(?is)player[s]?[^0-9]{0,20}([0-9]+)`)
+	reClears  = regexp.MustCompile(`(?is)clear(?:ed)?[^0-9]{0,20}([0-9]+)`)
+)
+
+func parseSearchPage(r io.Reader) (*Result, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("lr2ir: reading search page: %w", err)
+	}
+
+	result := &Result{}
+	if match := reTitle.FindSubmatch(b); len(match) > 1 {
+		result.Title = string(match[1])
+	}
+	if match := reArtist.FindSubmatch(b); len(match) > 1 {
+		result.Artist = string(match[1])
+	}
+	if match := rePlayers.FindSubmatch(b); len(match) > 1 {
+		fmt.Sscanf(string(match[1]), "%d", &result.Players)
+	}
+	if match := reClears.FindSubmatch(b); len(match) > 1 {
+		fmt.Sscanf(string(match[1]), "%d", &result.Clears)
+	}
+	return result, nil
+}
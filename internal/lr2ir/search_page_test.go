@@ -0,0 +1,54 @@
+package lr2ir
+
+import (
+	"strings"
+	"testing"
+)
+
+// searchPageFixture is a representative (trimmed-down) slice of the LR2IR
+// search page's hand-rolled HTML, enough to exercise each of
+// parseSearchPage's regexes against markup shaped like the real thing.
+const searchPageFixture = `
+<html><body>
+<table>
+<tr><td class="title">7 Colors</td></tr>
+<tr><td class="artist">dj TAKA</td></tr>
+<tr><td>players: 1234</td></tr>
+<tr><td>cleared: 567</td></tr>
+</table>
+</body></html>
+`
+
+func TestParseSearchPage_ExtractsAllFields(t *testing.T) {
+	result, err := parseSearchPage(strings.NewReader(searchPageFixture))
+	if err != nil {
+		t.Fatalf("parseSearchPage: %v", err)
+	}
+
+	if result.Title != "7 Colors" {
+		t.Errorf("Title = %q, want %q", result.Title, "7 Colors")
+	}
+	if result.Artist != "dj TAKA" {
+		t.Errorf("Artist = %q, want %q", result.Artist, "dj TAKA")
+	}
+	if result.Players != 1234 {
+		t.Errorf("Players = %d, want %d", result.Players, 1234)
+	}
+	if result.Clears != 567 {
+		t.Errorf("Clears = %d, want %d", result.Clears, 567)
+	}
+}
+
+// TestParseSearchPage_MissingFieldsLeftZero pins down the package comment's
+// documented behavior: a field the page doesn't match is left blank/zero
+// rather than failing the whole lookup.
+func TestParseSearchPage_MissingFieldsLeftZero(t *testing.T) {
+	result, err := parseSearchPage(strings.NewReader(`<html><body>no results</body></html>`))
+	if err != nil {
+		t.Fatalf("parseSearchPage: %v", err)
+	}
+
+	if result.Title != "" || result.Artist != "" || result.Players != 0 || result.Clears != 0 {
+		t.Errorf("parseSearchPage(no match) = %+v, want all zero values", result)
+	}
+}
@@ -0,0 +1,72 @@
+package lr2ir
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS lr2ir_cache (
+	md5        TEXT PRIMARY KEY,
+	title      TEXT NOT NULL DEFAULT '',
+	artist     TEXT NOT NULL DEFAULT '',
+	players    INTEGER NOT NULL DEFAULT 0,
+	clears     INTEGER NOT NULL DEFAULT 0,
+	fetched_at INTEGER NOT NULL
+);`
+
+// SQLiteCache stores LR2IR lookup results in a lr2ir_cache table, created
+// on first use.
+type SQLiteCache struct {
+	db *sql.DB
+}
+
+// NewSQLiteCache returns a Cache backed by db, creating the lr2ir_cache
+// table if it doesn't already exist.
+func NewSQLiteCache(ctx context.Context, db *sql.DB) (*SQLiteCache, error) {
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return nil, fmt.Errorf("Error creating table: %w", err)
+	}
+	return &SQLiteCache{db: db}, nil
+}
+
+func (c *SQLiteCache) Get(ctx context.Context, md5 string, ttl time.Duration) (*Result, bool, error) {
+	row := c.db.QueryRowContext(ctx,
+		"SELECT title, artist, players, clears, fetched_at FROM lr2ir_cache WHERE md5 = ?", md5)
+
+	var r Result
+	var fetchedAt int64
+	r.MD5 = md5
+	if err := row.Scan(&r.Title, &r.Artist, &r.Players, &r.Clears, &fetchedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("Error querying database: %w", err)
+	}
+	r.FetchedAt = time.Unix(fetchedAt, 0)
+
+	if ttl > 0 && time.Since(r.FetchedAt) > ttl {
+		return nil, false, nil
+	}
+	return &r, true, nil
+}
+
+func (c *SQLiteCache) Put(ctx context.Context, r *Result) error {
+	_, err := c.db.ExecContext(ctx, `
+INSERT INTO lr2ir_cache (md5, title, artist, players, clears, fetched_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(md5) DO UPDATE SET
+	title = excluded.title,
+	artist = excluded.artist,
+	players = excluded.players,
+	clears = excluded.clears,
+	fetched_at = excluded.fetched_at;`,
+		r.MD5, r.Title, r.Artist, r.Players, r.Clears, r.FetchedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("Error executing statement: %w", err)
+	}
+	return nil
+}
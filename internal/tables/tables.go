@@ -0,0 +1,118 @@
+// Package tables fetches BMS "difficulty tables": community-maintained
+// rankings of charts by perceived difficulty, published as a small
+// header.json pointing at a body JSON array of per-chart entries. The
+// convention (header carries name/symbol/data_url/level_order; the body
+// is a flat array of {md5, level, title, url, ...}) is the one used across
+// the BMS difficulty table ecosystem (insane BMS table, satellite, etc.).
+package tables
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Header is a difficulty table's header.json.
+type Header struct {
+	Name       string   `json:"name"`
+	Symbol     string   `json:"symbol"`
+	DataURL    string   `json:"data_url"`
+	LevelOrder []string `json:"level_order"`
+}
+
+// Entry is a single chart's entry in a difficulty table's body JSON.
+type Entry struct {
+	MD5     string `json:"md5"`
+	Level   string `json:"level"`
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	URLDiff string `json:"url_diff"`
+}
+
+// Fetch downloads headerURL, validates it, then downloads and parses the
+// body JSON it points at (data_url, resolved relative to headerURL).
+func Fetch(ctx context.Context, client *http.Client, headerURL string) (*Header, []Entry, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var header Header
+	if err := fetchJSON(ctx, client, headerURL, &header); err != nil {
+		return nil, nil, fmt.Errorf("tables: fetching header: %w", err)
+	}
+	if err := header.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	dataURL, err := resolveURL(headerURL, header.DataURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tables: resolving data_url: %w", err)
+	}
+
+	var entries []Entry
+	if err := fetchJSON(ctx, client, dataURL, &entries); err != nil {
+		return nil, nil, fmt.Errorf("tables: fetching data: %w", err)
+	}
+	for i, e := range entries {
+		if e.MD5 == "" {
+			return nil, nil, fmt.Errorf("tables: entry %d is missing md5", i)
+		}
+	}
+
+	return &header, entries, nil
+}
+
+// Validate reports whether h has the fields Fetch and the indexer rely on.
+func (h *Header) Validate() error {
+	if h.Name == "" {
+		return fmt.Errorf("tables: header is missing name")
+	}
+	if h.Symbol == "" {
+		return fmt.Errorf("tables: header is missing symbol")
+	}
+	if h.DataURL == "" {
+		return fmt.Errorf("tables: header is missing data_url")
+	}
+	return nil
+}
+
+func resolveURL(base, ref string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+func fetchJSON(ctx context.Context, client *http.Client, target string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return fmt.Errorf("building request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s for %s", resp.Status, target)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", target, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("parsing %s: %w", target, err)
+	}
+	return nil
+}
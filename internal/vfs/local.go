@@ -0,0 +1,202 @@
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+func init() {
+	Register("file", newLocalFS)
+}
+
+// localFS is a Filesystem backed directly by the local disk.
+type localFS struct {
+	root string
+	uri  string
+}
+
+func newLocalFS(u *url.URL) (Filesystem, error) {
+	return &localFS{root: u.Path, uri: u.String()}, nil
+}
+
+func (l *localFS) Type() string { return "file" }
+
+func (l *localFS) URI() string { return l.uri }
+
+func (l *localFS) Join(name string) string { return filepath.Join(l.root, name) }
+
+func (l *localFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, fn)
+}
+
+func (l *localFS) Stat(path string) (fs.FileInfo, error) { return os.Stat(path) }
+
+func (l *localFS) Open(path string) (io.ReadCloser, error) { return os.Open(path) }
+
+func (l *localFS) Create(path string) (io.WriteCloser, error) { return os.Create(path) }
+
+// Rename renames oldpath to newpath. If the two are on different
+// filesystems, os.Rename fails with EXDEV; Rename then falls back to a
+// recursive copy into a ".partial" sibling of newpath, atomically
+// renaming that into place (which, as a sibling, shares newpath's
+// filesystem and so cannot itself hit EXDEV) before removing oldpath. A
+// failure during the copy leaves oldpath untouched and removes the
+// partial, so a caller can safely retry.
+func (l *localFS) Rename(oldpath, newpath string) error {
+	err := os.Rename(oldpath, newpath)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	return crossDeviceRename(oldpath, newpath)
+}
+
+func (l *localFS) Remove(path string) error { return os.Remove(path) }
+
+func (l *localFS) RemoveAll(path string) error { return os.RemoveAll(path) }
+
+func (l *localFS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+
+// crossDeviceRename moves oldpath to newpath when they don't share a
+// filesystem, by copying into newpath+".partial", renaming that into
+// place, and only then deleting oldpath.
+func crossDeviceRename(oldpath, newpath string) error {
+	partial := newpath + ".partial"
+	if err := os.RemoveAll(partial); err != nil {
+		return fmt.Errorf("vfs: cleaning up stale partial %s: %w", partial, err)
+	}
+
+	if err := copyTree(oldpath, partial); err != nil {
+		os.RemoveAll(partial)
+		return fmt.Errorf("vfs: copying %s to %s: %w", oldpath, partial, err)
+	}
+
+	if err := os.Rename(partial, newpath); err != nil {
+		os.RemoveAll(partial)
+		return fmt.Errorf("vfs: renaming partial %s to %s: %w", partial, newpath, err)
+	}
+	if err := fsyncPath(filepath.Dir(newpath)); err != nil {
+		return fmt.Errorf("vfs: syncing %s: %w", filepath.Dir(newpath), err)
+	}
+
+	if err := os.RemoveAll(oldpath); err != nil {
+		return fmt.Errorf("vfs: removing source %s after cross-device move: %w", oldpath, err)
+	}
+	return nil
+}
+
+// dirStamp records the mode and mtime a copied directory needs restored
+// once its children have all been written (writing a child bumps its
+// parent's mtime, so this has to happen in a second, deepest-first pass).
+type dirStamp struct {
+	path    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// copyTree recursively copies src to dest, preserving mode, mtime and
+// symlinks, and fsyncing every file and directory it writes.
+func copyTree(src, dest string) error {
+	var dirs []dirStamp
+
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		destPath := filepath.Join(dest, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			return copySymlink(path, destPath)
+		case d.IsDir():
+			if err := os.MkdirAll(destPath, info.Mode().Perm()); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirStamp{path: destPath, mode: info.Mode(), modTime: info.ModTime()})
+			return nil
+		default:
+			return copyRegularFile(path, destPath, info)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := os.Chmod(d.path, d.mode.Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(d.path, d.modTime, d.modTime); err != nil {
+			return err
+		}
+		if err := fsyncPath(d.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyRegularFile(srcPath, destPath string, info fs.FileInfo) (err error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := dst.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	if _, err = io.Copy(dst, src); err != nil {
+		return err
+	}
+	if err = dst.Sync(); err != nil {
+		return err
+	}
+
+	return os.Chtimes(destPath, info.ModTime(), info.ModTime())
+}
+
+func copySymlink(srcPath, destPath string) error {
+	target, err := os.Readlink(srcPath)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(target, destPath)
+}
+
+// fsyncPath fsyncs the file or directory at path, so its contents (or,
+// for a directory, its entries) survive a crash.
+func fsyncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
@@ -0,0 +1,74 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCrossDeviceRename_CopiesTreeAndRemovesSource exercises the EXDEV
+// fallback path directly (rather than relying on oldpath/newpath actually
+// straddling a device boundary in the test environment): it must leave an
+// exact copy of the source tree, including a nested directory and a
+// symlink, under newpath, with oldpath gone and no ".partial" sibling
+// left behind.
+func TestCrossDeviceRename_CopiesTreeAndRemovesSource(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "top.txt"), []byte("top"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "nested.txt"), []byte("nested"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Symlink("top.txt", filepath.Join(src, "link.txt")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	dest := filepath.Join(root, "dest")
+	if err := crossDeviceRename(src, dest); err != nil {
+		t.Fatalf("crossDeviceRename: %v", err)
+	}
+
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("source still present after move: %v", err)
+	}
+	if _, err := os.Stat(dest + ".partial"); !os.IsNotExist(err) {
+		t.Errorf("partial directory left behind: %v", err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dest, "top.txt"))
+	if err != nil || string(b) != "top" {
+		t.Errorf("dest/top.txt = %q, %v, want %q, nil", b, err, "top")
+	}
+	b, err = os.ReadFile(filepath.Join(dest, "sub", "nested.txt"))
+	if err != nil || string(b) != "nested" {
+		t.Errorf("dest/sub/nested.txt = %q, %v, want %q, nil", b, err, "nested")
+	}
+	target, err := os.Readlink(filepath.Join(dest, "link.txt"))
+	if err != nil || target != "top.txt" {
+		t.Errorf("dest/link.txt readlink = %q, %v, want %q, nil", target, err, "top.txt")
+	}
+}
+
+// TestCrossDeviceRename_MissingSourceLeavesNoPartial makes sure a failed
+// copy doesn't leave a stray ".partial" directory around for a later
+// retry to stumble over.
+func TestCrossDeviceRename_MissingSourceLeavesNoPartial(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "does-not-exist")
+	dest := filepath.Join(root, "dest")
+
+	if err := crossDeviceRename(src, dest); err == nil {
+		t.Fatal("crossDeviceRename with a missing source: want error, got nil")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("dest created despite failed copy: %v", err)
+	}
+	if _, err := os.Stat(dest + ".partial"); !os.IsNotExist(err) {
+		t.Errorf("partial directory left behind after failed copy: %v", err)
+	}
+}
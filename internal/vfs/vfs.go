@@ -0,0 +1,79 @@
+// Package vfs abstracts file operations behind a Filesystem interface so
+// that callers like the quarantiner's directory mover don't need to know
+// whether a configured source or destination lives on local disk or some
+// remote store. A concrete backend registers itself under a URI scheme
+// (e.g. "file"); loadConfig-style callers resolve a srcDirs/destDir entry
+// to a backend with Open, the same way net/url or database/sql resolve a
+// driver from a connection string.
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+)
+
+// Filesystem is a single rooted tree of files, backed by local disk or a
+// remote store such as sftp or s3.
+type Filesystem interface {
+	// Type identifies the backend, e.g. "file". Two Filesystems with the
+	// same Type can exchange files with a native Rename; otherwise a
+	// caller must fall back to streamed copy+delete.
+	Type() string
+	// URI returns the URI the Filesystem was opened from.
+	URI() string
+	// Join joins name onto the Filesystem's root, returning a path
+	// usable with the Filesystem's other methods.
+	Join(name string) string
+
+	WalkDir(root string, fn fs.WalkDirFunc) error
+	Stat(path string) (fs.FileInfo, error)
+	Open(path string) (io.ReadCloser, error)
+	Create(path string) (io.WriteCloser, error)
+	Rename(oldpath, newpath string) error
+	Remove(path string) error
+	RemoveAll(path string) error
+	MkdirAll(path string, perm fs.FileMode) error
+}
+
+// Factory instantiates the backend registered for a URI's scheme.
+type Factory func(u *url.URL) (Filesystem, error)
+
+var registry = make(map[string]Factory)
+
+// Register associates a Factory with a URI scheme (e.g. "file", "sftp",
+// "s3"). Registering the same scheme twice overwrites the previous
+// Factory.
+func Register(scheme string, f Factory) {
+	registry[scheme] = f
+}
+
+// ParseURI parses raw as a URI. raw may omit its scheme, in which case it
+// is treated as a local path (e.g. "/data/bms" parses the same as
+// "file:///data/bms"), so existing config entries keep working unchanged.
+func ParseURI(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("vfs: invalid URI %q: %w", raw, err)
+	}
+	if u.Scheme == "" {
+		u.Scheme = "file"
+		u.Path = raw
+	}
+	return u, nil
+}
+
+// Open parses raw as a URI and instantiates the Filesystem registered for
+// its scheme.
+func Open(raw string) (Filesystem, error) {
+	u, err := ParseURI(raw)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := registry[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("vfs: no backend registered for scheme %q", u.Scheme)
+	}
+	return f(u)
+}
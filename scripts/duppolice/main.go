@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"flag"
@@ -11,13 +13,21 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/shuymn/bmm/internal/cdc"
 	"golang.org/x/sync/errgroup"
 )
 
-const maxConcurrency = 10
+const (
+	maxConcurrency     = 10
+	defaultThreshold   = 0.9
+	walkChannelBufSize = 64
+)
 
 var globalRand = rand.New(rand.NewSource(time.Now().UnixNano()))
 
@@ -29,72 +39,66 @@ type Config struct {
 }
 
 func main() {
-	var debug, merge bool
+	var srcs, exts stringSlice
+	var dest string
+	var minDuplicates int
+	var debug, merge, fuzzy bool
+	var threshold float64
+	flag.Var(&srcs, "src", "source directory to scan (repeatable)")
+	flag.Var(&exts, "ext", "chart file extension to scan, e.g. .bms (repeatable)")
+	flag.StringVar(&dest, "dest", "", "directory to move duplicate groups into")
+	flag.IntVar(&minDuplicates, "min-duplicates", 0, "minimum number of copies before a group is treated as a duplicate")
 	flag.BoolVar(&debug, "debug", false, "enable debug mode")
 	flag.BoolVar(&merge, "merge", false, "enable merge mode")
+	flag.BoolVar(&fuzzy, "fuzzy", false, "group near-duplicate folders by content-defined chunk similarity instead of whole-file checksum")
+	flag.Float64Var(&threshold, "threshold", defaultThreshold, "minimum Jaccard similarity for --fuzzy to treat two folders as duplicates")
 
 	flag.Parse()
 
-	config, err := loadConfig()
+	config, err := loadConfig(srcs, exts, dest, minDuplicates)
 	if err != nil {
 		fmt.Printf("Error loading config: %s", err)
 		return
 	}
 
-	checksums := make(map[string][]string)
-	for _, root := range config.Sources {
-		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if d.IsDir() {
-				if d.Name() == config.Destination {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-
-			if ok := contains(config.Extensions, filepath.Ext(path)); !ok {
-				return nil
-			}
-
-			checksum, err := calculateFileChecksum(path)
-			if err != nil {
-				return err
-			}
-			checksums[checksum] = append(checksums[checksum], path)
-			return nil
-		})
-		if err != nil {
-			fmt.Printf("Error walking directory: %s", err)
-			return
-		}
+	var groups map[string][]string
+	if fuzzy {
+		groups, err = scanFuzzy(config, threshold)
+	} else {
+		groups, err = scanExact(config)
+		groups = removeChecksumDuplication(groups)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
 
-	checksums = removeChecksumDuplication(checksums)
-
-	var totalChecksums, totalPaths int
-	for checksum, paths := range checksums {
+	var totalGroups, totalPaths int
+	for key, paths := range groups {
 		if len(paths) < config.MinDuplicates {
 			continue
 		}
 		if debug {
-			totalChecksums++
-			fmt.Println("checksum:", checksum)
+			totalGroups++
+			fmt.Println("group:", key)
 			for _, path := range paths {
 				totalPaths++
 				fmt.Println(" -", path)
 			}
 			continue
 		}
-		if err := moveDirectories(config.Destination, checksum, paths); err != nil {
+		groupID := key
+		if fuzzy {
+			groupID = hashGroupKey(key)
+		}
+		if err := moveDirectories(config.Destination, groupID, paths); err != nil {
 			fmt.Println(err)
 			return
 		}
 	}
 
 	if debug {
-		fmt.Println("total checksums:", totalChecksums)
+		fmt.Println("total groups:", totalGroups)
 		fmt.Println("total paths:", totalPaths)
 		return
 	}
@@ -121,20 +125,29 @@ func contains(s []string, target string) bool {
 	return false
 }
 
-func loadConfig() (config *Config, err error) {
-	file, err := os.Open("config.json")
-	if err != nil {
-		return nil, fmt.Errorf("Error opening file: %w", err)
-	}
-	defer file.Close()
+// stringSlice is a flag.Value that collects repeated occurrences of a
+// flag into a slice, e.g. -src a -src b.
+type stringSlice []string
 
-	b, err := io.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("Error reading file: %w", err)
-	}
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
 
-	if err := json.Unmarshal(b, &config); err != nil {
-		return nil, fmt.Errorf("Error parsing JSON: %w", err)
+func (s *stringSlice) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// loadConfig builds a Config from the given flags, falling back to
+// config.json only when none of them were set.
+func loadConfig(srcs, exts stringSlice, dest string, minDuplicates int) (config *Config, err error) {
+	if len(srcs) == 0 && len(exts) == 0 && dest == "" && minDuplicates == 0 {
+		config, err = loadConfigFile()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		config = &Config{Sources: srcs, Extensions: exts, Destination: dest, MinDuplicates: minDuplicates}
 	}
 
 	if len(config.Extensions) == 0 {
@@ -175,6 +188,25 @@ func loadConfig() (config *Config, err error) {
 	return config, nil
 }
 
+func loadConfigFile() (config *Config, err error) {
+	file, err := os.Open("config.json")
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file: %w", err)
+	}
+	defer file.Close()
+
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("Error parsing JSON: %w", err)
+	}
+
+	return config, nil
+}
+
 func isSubdirectory(parent, child string) bool {
 	parent = filepath.Clean(parent) + string(os.PathSeparator)
 	child = filepath.Clean(child)
@@ -236,6 +268,255 @@ func removeChecksumDuplication(checksums map[string][]string) map[string][]strin
 	return newChecksum
 }
 
+// walkChartPaths walks config.Sources for files matching config.Extensions
+// and fans them out to a worker pool sized runtime.NumCPU(), calling fn
+// once per file. The filepath.WalkDir producer and the workers are
+// connected by a buffered channel so walking the next directory overlaps
+// with processing the files already found. The first error from walking
+// or from fn cancels everything still in flight.
+func walkChartPaths(config *Config, fn func(path string) error) error {
+	paths := make(chan string, walkChannelBufSize)
+	g, ctx := errgroup.WithContext(context.Background())
+
+	g.Go(func() error {
+		defer close(paths)
+		for _, root := range config.Sources {
+			err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if d.Name() == config.Destination {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if ok := contains(config.Extensions, filepath.Ext(path)); !ok {
+					return nil
+				}
+				select {
+				case paths <- path:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	for i := 0; i < runtime.NumCPU(); i++ {
+		g.Go(func() error {
+			for path := range paths {
+				if err := fn(path); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+// stringSet is a concurrency-safe append-only string slice, used as the
+// value side of a sync.Map so multiple workers can accumulate into the
+// same key without racing.
+type stringSet struct {
+	mu    sync.Mutex
+	items []string
+}
+
+func (s *stringSet) add(v string) {
+	s.mu.Lock()
+	s.items = append(s.items, v)
+	s.mu.Unlock()
+}
+
+// scanExact walks config.Sources and groups files by whole-file MD5,
+// using walkChartPaths' worker pool so hashing overlaps with walking.
+func scanExact(config *Config) (map[string][]string, error) {
+	var checksums sync.Map
+
+	err := walkChartPaths(config, func(path string) error {
+		checksum, err := calculateFileChecksum(path)
+		if err != nil {
+			return err
+		}
+		actual, _ := checksums.LoadOrStore(checksum, &stringSet{})
+		actual.(*stringSet).add(path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error walking directory: %w", err)
+	}
+
+	result := make(map[string][]string)
+	checksums.Range(func(k, v any) bool {
+		result[k.(string)] = v.(*stringSet).items
+		return true
+	})
+	return result, nil
+}
+
+// folderChunks accumulates the content-defined chunk hashes of every
+// scanned file belonging to one chart folder, plus a representative file
+// path so the folder itself can later be found and moved.
+type folderChunks struct {
+	mu     sync.Mutex
+	path   string
+	chunks map[string]struct{}
+}
+
+func (f *folderChunks) addFile(path, ext string, hashes []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.path == "" {
+		f.path = path
+	}
+	for _, h := range hashes {
+		// Namespacing by extension keeps the Jaccard comparison below
+		// restricted to same-extension files, e.g. a .bms chunk never
+		// collides with a .wav chunk that happens to hash the same.
+		f.chunks[ext+":"+h] = struct{}{}
+	}
+}
+
+// scanFuzzy walks config.Sources computing a content-defined chunk
+// fingerprint set per chart folder (the union of its files' chunk
+// hashes), then groups folders whose chunk sets have a Jaccard
+// similarity at or above threshold. It bucket folders by any chunk hash
+// they share first, so the pairwise Jaccard comparison only runs inside
+// a bucket instead of across every folder pair.
+func scanFuzzy(config *Config, threshold float64) (map[string][]string, error) {
+	var folders sync.Map
+
+	err := walkChartPaths(config, func(path string) error {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("Error opening file: %w", err)
+		}
+		defer file.Close()
+
+		hashes, err := cdc.Chunks(file)
+		if err != nil {
+			return fmt.Errorf("Error chunking file: %w", err)
+		}
+
+		dir, _ := filepath.Split(path)
+		actual, _ := folders.LoadOrStore(dir, &folderChunks{chunks: make(map[string]struct{})})
+		actual.(*folderChunks).addFile(path, filepath.Ext(path), hashes)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error walking directory: %w", err)
+	}
+
+	type folder struct {
+		path   string
+		chunks map[string]struct{}
+	}
+	var all []*folder
+	folders.Range(func(_, v any) bool {
+		fc := v.(*folderChunks)
+		all = append(all, &folder{path: fc.path, chunks: fc.chunks})
+		return true
+	})
+
+	buckets := make(map[string][]int)
+	for i, f := range all {
+		for chunk := range f.chunks {
+			buckets[chunk] = append(buckets[chunk], i)
+		}
+	}
+
+	uf := newUnionFind(len(all))
+	for _, idxs := range buckets {
+		for a := 0; a < len(idxs); a++ {
+			for b := a + 1; b < len(idxs); b++ {
+				i, j := idxs[a], idxs[b]
+				if uf.find(i) == uf.find(j) {
+					continue
+				}
+				if jaccard(all[i].chunks, all[j].chunks) >= threshold {
+					uf.union(i, j)
+				}
+			}
+		}
+	}
+
+	groups := make(map[int][]string)
+	for i, f := range all {
+		root := uf.find(i)
+		groups[root] = append(groups[root], f.path)
+	}
+
+	result := make(map[string][]string)
+	for _, paths := range groups {
+		sort.Strings(paths)
+		result[strings.Join(paths, "|")] = paths
+	}
+	return result, nil
+}
+
+// jaccard returns the Jaccard similarity of two chunk-hash sets.
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	small, large := a, b
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	intersection := 0
+	for k := range small {
+		if _, ok := large[k]; ok {
+			intersection++
+		}
+	}
+	union := len(a) + len(b) - intersection
+	return float64(intersection) / float64(union)
+}
+
+// unionFind is a disjoint-set over folder indices, used to merge folders
+// transitively once any pair of them clears the similarity threshold.
+type unionFind struct {
+	parent []int
+}
+
+func newUnionFind(n int) *unionFind {
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	return &unionFind{parent: parent}
+}
+
+func (u *unionFind) find(i int) int {
+	for u.parent[i] != i {
+		u.parent[i] = u.parent[u.parent[i]]
+		i = u.parent[i]
+	}
+	return i
+}
+
+func (u *unionFind) union(i, j int) {
+	ri, rj := u.find(i), u.find(j)
+	if ri != rj {
+		u.parent[ri] = rj
+	}
+}
+
+// hashGroupKey maps an arbitrary fuzzy-group key (the sorted, joined
+// member paths) to a short, filesystem-safe directory name.
+func hashGroupKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
 func removeEmptyDirectory(destination, path string) error {
 	entries, err := os.ReadDir(path)
 	if err != nil {
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestJaccard(t *testing.T) {
+	set := func(items ...string) map[string]struct{} {
+		m := make(map[string]struct{}, len(items))
+		for _, it := range items {
+			m[it] = struct{}{}
+		}
+		return m
+	}
+
+	tests := []struct {
+		name string
+		a, b map[string]struct{}
+		want float64
+	}{
+		{"both empty", set(), set(), 0},
+		{"one empty", set("a"), set(), 0},
+		{"disjoint", set("a", "b"), set("c", "d"), 0},
+		{"identical", set("a", "b", "c"), set("a", "b", "c"), 1},
+		{"half overlap", set("a", "b"), set("b", "c"), 1.0 / 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jaccard(tt.a, tt.b); got != tt.want {
+				t.Errorf("jaccard(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnionFind_TransitiveMerge(t *testing.T) {
+	u := newUnionFind(5)
+	u.union(0, 1)
+	u.union(1, 2)
+	// 3 and 4 stay in their own singleton groups.
+
+	if u.find(0) != u.find(2) {
+		t.Errorf("0 and 2 should be transitively merged via 1, got roots %d and %d", u.find(0), u.find(2))
+	}
+	if u.find(0) == u.find(3) {
+		t.Errorf("0 and 3 were never unioned but share a root")
+	}
+	if u.find(3) == u.find(4) {
+		t.Errorf("3 and 4 were never unioned but share a root")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/shuymn/bmm/internal/vfs"
+)
+
+// corruptedHashesConfig is the optional config.json section that
+// identifies corrupted files by content instead of by base name, so a
+// corrupt file slipped past under a different name still gets caught.
+type corruptedHashesConfig struct {
+	SHA256 []string `json:"sha256"`
+	// SizeThreshold, when set, is the exact file size corrupted files in
+	// SHA256 are known to share; files of any other size are skipped
+	// without hashing. Zero disables the filter and hashes every file.
+	SizeThreshold int64 `json:"sizeThreshold"`
+}
+
+// Detector flags a single file as corrupted. Match receives the file's
+// already-stat'd info so cheap detectors (e.g. by name) never need to
+// touch the file, and expensive ones (e.g. by hash) can pre-filter on
+// size before reading it. fsys is the Filesystem path was found on, so a
+// detector that needs the file's content reads it the same way the rest
+// of the quarantiner does, instead of assuming local disk.
+type Detector interface {
+	Match(fsys vfs.Filesystem, path string, info fs.FileInfo) (bool, error)
+}
+
+// buildDetectors assembles the quarantiner's detector chain: the legacy
+// filename allowlist, plus a hash-based detector when config.json
+// declares one. Both run for every file; the first to match wins.
+func buildDetectors(names map[string]struct{}, cfg *corruptedHashesConfig) []Detector {
+	detectors := []Detector{&filenameDetector{names: names}}
+
+	if cfg != nil && len(cfg.SHA256) > 0 {
+		hashes := make(map[string]struct{}, len(cfg.SHA256))
+		for _, h := range cfg.SHA256 {
+			hashes[strings.ToLower(h)] = struct{}{}
+		}
+		detectors = append(detectors, &hashDetector{hashes: hashes, sizeThreshold: cfg.SizeThreshold})
+	}
+
+	return detectors
+}
+
+// anyDetectorMatches reports whether any of detectors flags path as
+// corrupted, short-circuiting on the first match.
+func anyDetectorMatches(detectors []Detector, fsys vfs.Filesystem, path string, info fs.FileInfo) (bool, error) {
+	for _, d := range detectors {
+		ok, err := d.Match(fsys, path, info)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// filenameDetector matches files by exact base name against the legacy
+// corrupted.json allowlist.
+type filenameDetector struct {
+	names map[string]struct{}
+}
+
+func (d *filenameDetector) Match(_ vfs.Filesystem, path string, _ fs.FileInfo) (bool, error) {
+	_, ok := d.names[filepath.Base(path)]
+	return ok, nil
+}
+
+// hashDetector matches files by content, stream-hashing candidates with
+// sha256 and checking the result against a known-corrupted set.
+type hashDetector struct {
+	hashes        map[string]struct{}
+	sizeThreshold int64
+}
+
+func (d *hashDetector) Match(fsys vfs.Filesystem, path string, info fs.FileInfo) (bool, error) {
+	if d.sizeThreshold > 0 && info.Size() != d.sizeThreshold {
+		return false, nil
+	}
+
+	sum, err := sha256File(fsys, path)
+	if err != nil {
+		return false, err
+	}
+	_, ok := d.hashes[sum]
+	return ok, nil
+}
+
+func sha256File(fsys vfs.Filesystem, path string) (string, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("Error opening file: %w", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("Error hashing file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
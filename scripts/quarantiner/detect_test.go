@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/shuymn/bmm/internal/vfs"
+)
+
+// memFS is a minimal in-memory Filesystem whose paths are arbitrary keys
+// with no relation to the local disk, so a detector that bypassed the
+// Filesystem it was given (e.g. by calling os.Open directly) would fail
+// to find the file at all, rather than merely reading the right bytes by
+// coincidence.
+type memFS struct {
+	files map[string][]byte
+}
+
+func (m *memFS) Type() string            { return "mem" }
+func (m *memFS) URI() string             { return "mem://test" }
+func (m *memFS) Join(name string) string { return name }
+func (m *memFS) WalkDir(string, fs.WalkDirFunc) error {
+	return nil
+}
+
+func (m *memFS) Stat(path string) (fs.FileInfo, error) {
+	b, ok := m.files[path]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return memFileInfo{name: path, size: int64(len(b))}, nil
+}
+
+func (m *memFS) Open(path string) (io.ReadCloser, error) {
+	b, ok := m.files[path]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (m *memFS) Create(path string) (io.WriteCloser, error) {
+	return nil, fs.ErrInvalid
+}
+func (m *memFS) Rename(string, string) error        { return fs.ErrInvalid }
+func (m *memFS) Remove(string) error                { return fs.ErrInvalid }
+func (m *memFS) RemoveAll(string) error             { return fs.ErrInvalid }
+func (m *memFS) MkdirAll(string, fs.FileMode) error { return nil }
+
+type memFileInfo struct {
+	name string
+	size int64
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() fs.FileMode  { return 0o644 }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return false }
+func (i memFileInfo) Sys() interface{}   { return nil }
+
+// TestHashDetector_MatchUsesGivenFilesystem pins down that hashDetector
+// reads the candidate file through the Filesystem passed to Match rather
+// than hard-wiring local disk access via os.Open, so it keeps working
+// once a non-local backend is registered.
+func TestHashDetector_MatchUsesGivenFilesystem(t *testing.T) {
+	const path = "mem-key-with-no-local-disk-equivalent"
+	content := []byte("corrupted chart bytes")
+	sum := sha256.Sum256(content)
+
+	fsys := &memFS{files: map[string][]byte{path: content}}
+
+	info, err := fsys.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+
+	d := &hashDetector{hashes: map[string]struct{}{hex.EncodeToString(sum[:]): {}}}
+	ok, err := d.Match(fsys, path, info)
+	if err != nil {
+		t.Fatalf("Match: %v (a detector calling os.Open directly would fail to find this in-memory path)", err)
+	}
+	if !ok {
+		t.Errorf("Match = false, want true")
+	}
+}
+
+var _ vfs.Filesystem = (*memFS)(nil)
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// journalEntry is one line of a journal file: a single successful move,
+// recorded so `bmm undo` can later move dest back to src. SrcBackend and
+// DestBackend are the source and destination Filesystems' URIs (not just
+// their Type), since that's what vfs.Open needs to reconstruct the same
+// Filesystems later. They're recorded separately because moveDirectory
+// allows a source and destination to live on different backends.
+type journalEntry struct {
+	Time        time.Time `json:"time"`
+	Src         string    `json:"src"`
+	Dest        string    `json:"dest"`
+	SrcBackend  string    `json:"src_backend"`
+	DestBackend string    `json:"dest_backend"`
+}
+
+// journal appends a journalEntry per successful move to a JSON Lines
+// file, so the run can be undone later. A nil *journal is a valid no-op,
+// for when -journal wasn't given.
+type journal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openJournal opens path for appending, creating it if necessary. It
+// returns a nil *journal (not an error) when path is empty.
+func openJournal(path string) (*journal, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening journal: %w", err)
+	}
+	return &journal{file: file}, nil
+}
+
+func (j *journal) record(src, dest, srcBackend, destBackend string) error {
+	if j == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(journalEntry{
+		Time:        time.Now(),
+		Src:         src,
+		Dest:        dest,
+		SrcBackend:  srcBackend,
+		DestBackend: destBackend,
+	})
+	if err != nil {
+		return fmt.Errorf("Error marshaling journal entry: %w", err)
+	}
+	b = append(b, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.file.Write(b)
+	return err
+}
+
+func (j *journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
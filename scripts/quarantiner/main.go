@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -8,20 +9,76 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/shuymn/bmm/internal/vfs"
+)
+
+const (
+	defaultMoveWorkers = 4
+	dirChannelBufSize  = 64
+	progressInterval   = 5 * time.Second
 )
 
+// rawConfig is the on-disk/flag shape of the quarantiner's configuration,
+// before its srcDirs/destDir entries are resolved to a Filesystem.
+type rawConfig struct {
+	Sources         []string               `json:"srcDirs"`
+	Destination     string                 `json:"destDir"`
+	CorruptedHashes *corruptedHashesConfig `json:"corruptedHashes,omitempty"`
+	ConflictPolicy  string                 `json:"conflictPolicy,omitempty"`
+}
+
 type Config struct {
-	Sources     []string `json:"srcDirs"`
-	Destination string   `json:"destDir"`
+	Sources         []vfs.Filesystem
+	Destination     vfs.Filesystem
+	CorruptedHashes *corruptedHashesConfig
+	ConflictPolicy  conflictPolicy
+}
+
+// quarantineDir is a chart folder found to contain a corrupted file,
+// paired with the Filesystem it was found on so moveDirectory knows
+// whether it can use a native rename or must stream a copy, and with the
+// configured srcDir it was found under so conflictPreserveTree can
+// reconstruct its path relative to that root.
+type quarantineDir struct {
+	fs      vfs.Filesystem
+	path    string
+	srcRoot string
 }
 
 func main() {
-	var debug bool
+	if len(os.Args) > 1 && os.Args[1] == "undo" {
+		runUndo(os.Args[2:])
+		return
+	}
+
+	var srcs stringSlice
+	var dest, journalPath, conflictPolicyFlag string
+	var debug, progress, dryRun bool
+	var workers int
+	flag.Var(&srcs, "src", "source directory to scan (repeatable)")
+	flag.StringVar(&dest, "dest", "", "directory to move quarantined chart folders into")
 	flag.BoolVar(&debug, "debug", false, "enable debug mode")
+	flag.IntVar(&workers, "workers", defaultMoveWorkers, "number of concurrent mover workers")
+	flag.BoolVar(&progress, "progress", false, "periodically print scan/match/move counts")
+	flag.BoolVar(&dryRun, "dry-run", false, "walk and print planned moves without touching the filesystem")
+	flag.StringVar(&journalPath, "journal", "", "append a JSON line per successful move here, for a later `bmm undo -journal`")
+	flag.StringVar(&conflictPolicyFlag, "conflict-policy", "", "how to resolve a destDir collision: error, skip, suffix, preserveTree (default error)")
 
 	flag.Parse()
 
-	config, err := loadConfig()
+	if workers < 1 {
+		fmt.Println("Error: workers must be at least 1")
+		return
+	}
+
+	config, err := loadConfig(srcs, dest, conflictPolicyFlag)
 	if err != nil {
 		fmt.Printf("Error loading config.json: %s", err)
 		return
@@ -32,90 +89,260 @@ func main() {
 		fmt.Printf("Error loading corrupted.json: %s", err)
 		return
 	}
+	detectors := buildDetectors(corrupted, config.CorruptedHashes)
 
-	dirs := make([]string, 0, 10000)
-	for _, root := range config.Sources {
-		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
+	j, err := openJournal(journalPath)
+	if err != nil {
+		fmt.Printf("Error opening journal: %s", err)
+		return
+	}
+	defer j.Close()
+
+	var stats progressStats
+	if progress {
+		stop := startProgressReporter(&stats)
+		defer stop()
+	}
+
+	if err := quarantine(config, detectors, workers, debug, dryRun, j, &stats); err != nil {
+		fmt.Printf("Error quarantining directories: %s", err)
+		return
+	}
+}
+
+// progressStats are counters updated concurrently by walkers and movers,
+// read back by the optional progress reporter.
+type progressStats struct {
+	scanned atomic.Int64
+	matched atomic.Int64
+	moved   atomic.Int64
+}
+
+// startProgressReporter prints stats every progressInterval, and once
+// more before returning, until the returned stop func is called.
+func startProgressReporter(stats *progressStats) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				printProgress(stats)
+			case <-done:
+				printProgress(stats)
+				return
 			}
-			if d.IsDir() {
-				if d.Name() == config.Destination {
-					return filepath.SkipDir
+		}
+	}()
+	return func() { close(done) }
+}
+
+func printProgress(stats *progressStats) {
+	fmt.Printf("scanned: %d, matched: %d, moved: %d\n", stats.scanned.Load(), stats.matched.Load(), stats.moved.Load())
+}
+
+// quarantine walks every configured source in its own goroutine, pushing
+// each corrupted directory found onto a channel drained by a fixed pool
+// of mover workers, so walking and moving overlap instead of the latter
+// waiting for the former to fully finish. A destDir collision no longer
+// aborts the run: moveDirectory resolves it per config.ConflictPolicy and
+// quarantine collects every directory's outcome into a summary printed
+// once all movers are done.
+func quarantine(config *Config, detectors []Detector, workers int, debug, dryRun bool, j *journal, stats *progressStats) error {
+	dirs := make(chan quarantineDir, dirChannelBufSize)
+	g, ctx := errgroup.WithContext(context.Background())
+
+	var walkers sync.WaitGroup
+	for _, fsys := range config.Sources {
+		fsys := fsys
+		walkers.Add(1)
+		g.Go(func() error {
+			defer walkers.Done()
+			return walkSource(ctx, fsys, config.Destination, detectors, dirs, debug, stats)
+		})
+	}
+
+	g.Go(func() error {
+		walkers.Wait()
+		close(dirs)
+		return nil
+	})
+
+	var outcomes outcomeList
+	var destMu sync.Mutex
+	for i := 0; i < workers; i++ {
+		g.Go(func() error {
+			for dir := range dirs {
+				outcome, err := moveDirectory(config.Destination, dir, config.ConflictPolicy, dryRun, j, &destMu)
+				if err != nil {
+					return err
+				}
+				outcomes.add(outcome)
+				if outcome.status == moveMoved {
+					stats.moved.Add(1)
 				}
-				return nil
 			}
+			return nil
+		})
+	}
+
+	err := g.Wait()
+	printSummary(outcomes.list())
+	return err
+}
 
-			if ok := contains(corrupted, filepath.Base(path)); ok {
-				dirs = append(dirs, filepath.Dir(path))
+// walkSource walks fsys for files any of detectors flags as corrupted,
+// sending the containing directory to dirs. It skips back out of a
+// matched directory rather than descending into it, same as the original
+// serial scan.
+func walkSource(ctx context.Context, fsys, destFS vfs.Filesystem, detectors []Detector, dirs chan<- quarantineDir, debug bool, stats *progressStats) error {
+	root := fsys.Join(".")
+	return fsys.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == destFS.URI() {
 				return filepath.SkipDir
 			}
 			return nil
-		})
+		}
+
+		stats.scanned.Add(1)
+
+		info, err := d.Info()
 		if err != nil {
-			fmt.Printf("Error walking directory: %s", err)
-			return
+			return err
 		}
-	}
+		matched, err := anyDetectorMatches(detectors, fsys, path, info)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return nil
+		}
+		stats.matched.Add(1)
 
-	if debug {
-		for _, dir := range dirs {
-			fmt.Println(dir)
+		dir := quarantineDir{fs: fsys, path: filepath.Dir(path), srcRoot: root}
+		if debug {
+			fmt.Println(dir.path)
 		}
-	}
 
-	if err = moveDirectories(config.Destination, dirs); err != nil {
-		fmt.Printf("Error moving directories: %s", err)
-		return
-	}
+		select {
+		case dirs <- dir:
+			return filepath.SkipDir
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
 }
 
-func loadConfig() (config *Config, err error) {
-	file, err := os.Open("config.json")
-	if err != nil {
-		return nil, fmt.Errorf("Error opening file: %w", err)
-	}
-	defer file.Close()
-
-	b, err := io.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("Error reading file: %w", err)
-	}
-
-	if err := json.Unmarshal(b, &config); err != nil {
-		return nil, fmt.Errorf("Error parsing JSON: %w", err)
+// loadConfig builds a Config from the given flags, falling back to
+// config.json only when none of them were set. Each srcDirs/destDir
+// entry is parsed as a URI and resolved to a Filesystem via vfs.Open; a
+// bare absolute path (the historical config.json shape) is treated as a
+// file:// URI, so existing configs keep working unchanged.
+func loadConfig(srcs stringSlice, dest, conflictPolicyFlag string) (config *Config, err error) {
+	var raw *rawConfig
+	if len(srcs) == 0 && dest == "" {
+		raw, err = loadConfigFile()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		raw = &rawConfig{Sources: srcs, Destination: dest, ConflictPolicy: conflictPolicyFlag}
 	}
 
-	if len(config.Sources) == 0 {
+	if len(raw.Sources) == 0 {
 		return nil, fmt.Errorf("srcDirs must not be empty")
 	}
-	if config.Destination == "" {
+	if raw.Destination == "" {
 		return nil, fmt.Errorf("destDir must not be empty")
 	}
 
-	if !filepath.IsAbs(config.Destination) {
-		return nil, fmt.Errorf("destDir (%s) must not be a relative path", config.Destination)
-	}
-
-	if err = checkDirectoryExistance(config.Destination); err != nil {
+	destPath, destLocal, err := localPath(raw.Destination)
+	if err != nil {
 		return nil, err
 	}
+	if destLocal {
+		if !filepath.IsAbs(destPath) {
+			return nil, fmt.Errorf("destDir (%s) must not be a relative path", destPath)
+		}
+		if err = checkDirectoryExistance(destPath); err != nil {
+			return nil, err
+		}
+	}
 
-	for _, src := range config.Sources {
-		if !filepath.IsAbs(src) {
-			return nil, fmt.Errorf("srcDir (%s) must not be a relative path", src)
+	config = &Config{}
+	for _, src := range raw.Sources {
+		srcPath, srcLocal, err := localPath(src)
+		if err != nil {
+			return nil, err
 		}
-		if isSubdirectory(src, config.Destination) {
-			return nil, fmt.Errorf("destDir (%s) must not be a subdirectory of any srcDirs", config.Destination)
+		if srcLocal {
+			if !filepath.IsAbs(srcPath) {
+				return nil, fmt.Errorf("srcDir (%s) must not be a relative path", srcPath)
+			}
+			if destLocal && isSubdirectory(srcPath, destPath) {
+				return nil, fmt.Errorf("destDir (%s) must not be a subdirectory of any srcDirs", destPath)
+			}
+			if err = checkDirectoryExistance(srcPath); err != nil {
+				return nil, err
+			}
 		}
-		if err = checkDirectoryExistance(src); err != nil {
+
+		fsys, err := vfs.Open(src)
+		if err != nil {
 			return nil, err
 		}
+		config.Sources = append(config.Sources, fsys)
+	}
+
+	if config.Destination, err = vfs.Open(raw.Destination); err != nil {
+		return nil, err
+	}
+
+	config.CorruptedHashes = raw.CorruptedHashes
+
+	if config.ConflictPolicy, err = parseConflictPolicy(raw.ConflictPolicy); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
 
+// localPath returns raw's filesystem path and whether raw addresses the
+// local disk (a bare path, or an explicit file:// URI), as opposed to a
+// remote backend whose path isn't meaningful to the local checks above.
+func localPath(raw string) (path string, local bool, err error) {
+	u, err := vfs.ParseURI(raw)
+	if err != nil {
+		return "", false, err
+	}
+	return u.Path, u.Scheme == "file", nil
+}
+
+func loadConfigFile() (*rawConfig, error) {
+	file, err := os.Open("config.json")
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file: %w", err)
+	}
+	defer file.Close()
+
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file: %w", err)
+	}
+
+	var raw rawConfig
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("Error parsing JSON: %w", err)
+	}
+
+	return &raw, nil
+}
+
 func isSubdirectory(parent, child string) bool {
 	parent = filepath.Clean(parent) + string(os.PathSeparator)
 	child = filepath.Clean(child)
@@ -133,7 +360,10 @@ func checkDirectoryExistance(path string) error {
 	return nil
 }
 
-func loadCorrupted() ([]string, error) {
+// loadCorrupted loads corrupted.json's list of corrupted filenames into a
+// set, so walkSource's per-file lookup is O(1) instead of scanning the
+// (potentially 50,000-entry) list for every file found.
+func loadCorrupted() (map[string]struct{}, error) {
 	file, err := os.Open("corrupted.json")
 	if err != nil {
 		return nil, fmt.Errorf("Error opening file: %w", err)
@@ -145,41 +375,273 @@ func loadCorrupted() ([]string, error) {
 		return nil, fmt.Errorf("Error reading file: %w", err)
 	}
 
-	corrupted := make([]string, 0, 50000)
-	if err := json.Unmarshal(b, &corrupted); err != nil {
+	names := make([]string, 0, 50000)
+	if err := json.Unmarshal(b, &names); err != nil {
 		return nil, fmt.Errorf("Error parsing JSON: %w", err)
 	}
+
+	corrupted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		corrupted[name] = struct{}{}
+	}
 	return corrupted, nil
 }
 
-func contains(s []string, target string) bool {
-	for _, v := range s {
-		if v == target {
-			return true
+// stringSlice is a flag.Value that collects repeated occurrences of a
+// flag into a slice, e.g. -src a -src b.
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// conflictPolicy controls how moveDirectory resolves a destDir that
+// already exists, so the same basename appearing under multiple srcDirs
+// doesn't have to abort the whole run.
+type conflictPolicy string
+
+const (
+	conflictError        conflictPolicy = "error"
+	conflictSkip         conflictPolicy = "skip"
+	conflictSuffix       conflictPolicy = "suffix"
+	conflictPreserveTree conflictPolicy = "preserveTree"
+)
+
+// parseConflictPolicy validates raw, defaulting an empty string to
+// conflictError, the historical behavior.
+func parseConflictPolicy(raw string) (conflictPolicy, error) {
+	switch conflictPolicy(raw) {
+	case "":
+		return conflictError, nil
+	case conflictError, conflictSkip, conflictSuffix, conflictPreserveTree:
+		return conflictPolicy(raw), nil
+	default:
+		return "", fmt.Errorf("conflictPolicy must be one of error, skip, suffix, preserveTree, got %q", raw)
+	}
+}
+
+// moveStatus is the outcome of one moveDirectory attempt.
+type moveStatus string
+
+const (
+	moveMoved    moveStatus = "moved"
+	moveSkipped  moveStatus = "skipped"
+	moveConflict moveStatus = "conflict"
+)
+
+// moveOutcome records what happened to a single quarantined directory, so
+// quarantine can report per-directory results in a summary instead of
+// bailing on the first destDir collision.
+type moveOutcome struct {
+	src    string
+	dest   string
+	status moveStatus
+}
+
+// outcomeList accumulates moveOutcomes from concurrent mover workers.
+type outcomeList struct {
+	mu    sync.Mutex
+	items []moveOutcome
+}
+
+func (o *outcomeList) add(item moveOutcome) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.items = append(o.items, item)
+}
+
+func (o *outcomeList) list() []moveOutcome {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.items
+}
+
+// printSummary prints a count per status followed by the individual
+// directories that weren't moved, so a run with skips or conflicts still
+// says exactly which ones need a second look.
+func printSummary(outcomes []moveOutcome) {
+	counts := make(map[moveStatus]int)
+	for _, o := range outcomes {
+		counts[o.status]++
+	}
+
+	fmt.Println("Summary:")
+	for _, status := range []moveStatus{moveMoved, moveSkipped, moveConflict} {
+		fmt.Printf("  %s: %d\n", status, counts[status])
+	}
+	for _, o := range outcomes {
+		if o.status != moveMoved {
+			fmt.Printf("  %s: %s (wanted %s)\n", o.status, o.src, o.dest)
+		}
+	}
+}
+
+// moveDirectory moves one quarantined directory into destFS. When the
+// directory's own Filesystem shares destFS's backend type, it uses a
+// native rename; otherwise it streams a recursive copy and then deletes
+// the original. Safe to call concurrently across different dirs: destMu
+// serializes resolveDestDir's collision check together with the
+// directory creation and rename/copy that follow it, so two workers
+// racing on the same candidate basename can't both pass the check before
+// either one creates the destination. With dryRun, it only prints the
+// move it would have made. On a real move, it appends an entry to j (a
+// no-op if j is nil) so `bmm undo` can reverse it. A destDir collision is
+// resolved per policy and reported back as a moveOutcome rather than as
+// an error, so it doesn't abort other in-flight moves; the returned
+// error is reserved for genuine I/O failures.
+func moveDirectory(destFS vfs.Filesystem, dir quarantineDir, policy conflictPolicy, dryRun bool, j *journal, destMu *sync.Mutex) (moveOutcome, error) {
+	if _, err := dir.fs.Stat(dir.path); err != nil {
+		return moveOutcome{}, fmt.Errorf("Error checking directory: %w", err)
+	}
+
+	destMu.Lock()
+	defer destMu.Unlock()
+
+	destDir, status, err := resolveDestDir(destFS, dir, policy)
+	if err != nil {
+		return moveOutcome{}, err
+	}
+	if status != "" {
+		fmt.Printf("%s %s: destination already exists at %s\n", status, dir.path, destDir)
+		return moveOutcome{src: dir.path, dest: destDir, status: status}, nil
+	}
+
+	if dryRun {
+		fmt.Printf("Would move directory: %s -> %s\n", dir.path, destDir)
+		return moveOutcome{src: dir.path, dest: destDir, status: moveMoved}, nil
+	}
+
+	// conflictPreserveTree can nest destDir several levels below destFS's
+	// root, so its parent needs creating before a rename or copy into it.
+	if err := destFS.MkdirAll(filepath.Dir(destDir), 0755); err != nil {
+		return moveOutcome{}, fmt.Errorf("Error creating destination directory: %w", err)
+	}
+
+	if dir.fs.Type() == destFS.Type() {
+		if err := dir.fs.Rename(dir.path, destDir); err != nil {
+			return moveOutcome{}, fmt.Errorf("Error moving directory: %w", err)
+		}
+	} else {
+		if err := copyDir(dir.fs, dir.path, destFS, destDir); err != nil {
+			return moveOutcome{}, fmt.Errorf("Error copying directory: %w", err)
+		}
+		if err := dir.fs.RemoveAll(dir.path); err != nil {
+			return moveOutcome{}, fmt.Errorf("Error deleting directory: %w", err)
 		}
 	}
-	return false
+	fmt.Printf("Successfully moved directory to: %s\n", destDir)
+
+	if err := j.record(dir.path, destDir, dir.fs.URI(), destFS.URI()); err != nil {
+		return moveOutcome{}, fmt.Errorf("Error recording journal entry: %w", err)
+	}
+	return moveOutcome{src: dir.path, dest: destDir, status: moveMoved}, nil
 }
 
-func moveDirectories(dest string, srcDirs []string) error {
-	for _, srcDir := range srcDirs {
-		if _, err := os.Stat(srcDir); err != nil {
-			return fmt.Errorf("Error checking directory: %w", err)
+// resolveDestDir picks destDir's destination path under policy. It
+// returns a non-empty status when the directory should not be moved
+// (moveSkipped or moveConflict); a real error is reserved for Stat
+// failures unrelated to the collision itself.
+func resolveDestDir(destFS vfs.Filesystem, dir quarantineDir, policy conflictPolicy) (destDir string, status moveStatus, err error) {
+	candidate := destFS.Join(filepath.Base(dir.path))
+	exists, err := destExists(destFS, candidate)
+	if err != nil {
+		return "", "", err
+	}
+	if !exists {
+		return candidate, "", nil
+	}
+
+	switch policy {
+	case conflictSkip:
+		return candidate, moveSkipped, nil
+
+	case conflictSuffix:
+		base := filepath.Base(dir.path)
+		for i := 1; ; i++ {
+			suffixed := destFS.Join(fmt.Sprintf("%s-%d", base, i))
+			exists, err := destExists(destFS, suffixed)
+			if err != nil {
+				return "", "", err
+			}
+			if !exists {
+				return suffixed, "", nil
+			}
 		}
 
-		destDir := filepath.Join(dest, filepath.Base(srcDir))
-		_, err := os.Stat(destDir)
-		if err == nil {
-			return fmt.Errorf("Destination directory already exists: %s", destDir)
+	case conflictPreserveTree:
+		rel, err := filepath.Rel(dir.srcRoot, dir.path)
+		if err != nil {
+			return "", "", fmt.Errorf("Error computing relative path: %w", err)
+		}
+		treeDir := destFS.Join(rel)
+		treeExists, err := destExists(destFS, treeDir)
+		if err != nil {
+			return "", "", err
 		}
-		if !os.IsNotExist(err) {
-			return fmt.Errorf("Error checking directory: %w", err)
+		if treeExists {
+			return treeDir, moveConflict, nil
 		}
+		return treeDir, "", nil
+
+	default: // conflictError
+		return candidate, moveConflict, nil
+	}
+}
+
+// destExists reports whether path already exists on destFS.
+func destExists(destFS vfs.Filesystem, path string) (bool, error) {
+	if _, err := destFS.Stat(path); err == nil {
+		return true, nil
+	} else if os.IsNotExist(err) {
+		return false, nil
+	} else {
+		return false, fmt.Errorf("Error checking directory: %w", err)
+	}
+}
 
-		if err := os.Rename(srcDir, destDir); err != nil {
-			return fmt.Errorf("Error moving directory: %w", err)
+// copyDir streams srcDir's tree from srcFS into destDir on destFS, for
+// use when the two don't share a backend and can't be renamed across.
+func copyDir(srcFS vfs.Filesystem, srcDir string, destFS vfs.Filesystem, destDir string) error {
+	return srcFS.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		destPath := filepath.Join(destDir, rel)
+		if d.IsDir() {
+			return destFS.MkdirAll(destPath, 0755)
 		}
-		fmt.Printf("Successfully moved directory to: %s\n", destDir)
+		return copyFile(srcFS, path, destFS, destPath)
+	})
+}
+
+func copyFile(srcFS vfs.Filesystem, srcPath string, destFS vfs.Filesystem, destPath string) error {
+	src, err := srcFS.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("Error opening file: %w", err)
+	}
+	defer src.Close()
+
+	if err := destFS.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("Error creating destination directory: %w", err)
+	}
+
+	dst, err := destFS.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("Error creating file: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("Error copying file: %w", err)
 	}
 	return nil
 }
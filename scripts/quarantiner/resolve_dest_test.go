@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/shuymn/bmm/internal/vfs"
+)
+
+func openLocalFS(t *testing.T, root string) vfs.Filesystem {
+	t.Helper()
+	fsys, err := vfs.Open("file://" + root)
+	if err != nil {
+		t.Fatalf("vfs.Open: %v", err)
+	}
+	return fsys
+}
+
+func TestResolveDestDir_NoCollision(t *testing.T) {
+	destRoot := t.TempDir()
+	destFS := openLocalFS(t, destRoot)
+
+	srcRoot := t.TempDir()
+	dir := quarantineDir{path: filepath.Join(srcRoot, "chart"), srcRoot: srcRoot}
+
+	for _, policy := range []conflictPolicy{conflictError, conflictSkip, conflictSuffix, conflictPreserveTree} {
+		destDir, status, err := resolveDestDir(destFS, dir, policy)
+		if err != nil {
+			t.Fatalf("policy %s: resolveDestDir: %v", policy, err)
+		}
+		if status != "" {
+			t.Errorf("policy %s: status = %q, want empty (no collision)", policy, status)
+		}
+		if policy != conflictPreserveTree && destDir != destFS.Join("chart") {
+			t.Errorf("policy %s: destDir = %q, want %q", policy, destDir, destFS.Join("chart"))
+		}
+	}
+}
+
+func TestResolveDestDir_ConflictPolicy(t *testing.T) {
+	destRoot := t.TempDir()
+	destFS := openLocalFS(t, destRoot)
+	if err := os.MkdirAll(destFS.Join("chart"), 0755); err != nil {
+		t.Fatalf("seeding destination collision: %v", err)
+	}
+
+	srcRoot := t.TempDir()
+	dir := quarantineDir{path: filepath.Join(srcRoot, "chart"), srcRoot: srcRoot}
+
+	destDir, status, err := resolveDestDir(destFS, dir, conflictError)
+	if err != nil {
+		t.Fatalf("resolveDestDir: %v", err)
+	}
+	if status != moveConflict {
+		t.Errorf("status = %q, want %q", status, moveConflict)
+	}
+	if destDir != destFS.Join("chart") {
+		t.Errorf("destDir = %q, want %q", destDir, destFS.Join("chart"))
+	}
+}
+
+func TestResolveDestDir_SkipPolicy(t *testing.T) {
+	destRoot := t.TempDir()
+	destFS := openLocalFS(t, destRoot)
+	if err := os.MkdirAll(destFS.Join("chart"), 0755); err != nil {
+		t.Fatalf("seeding destination collision: %v", err)
+	}
+
+	srcRoot := t.TempDir()
+	dir := quarantineDir{path: filepath.Join(srcRoot, "chart"), srcRoot: srcRoot}
+
+	_, status, err := resolveDestDir(destFS, dir, conflictSkip)
+	if err != nil {
+		t.Fatalf("resolveDestDir: %v", err)
+	}
+	if status != moveSkipped {
+		t.Errorf("status = %q, want %q", status, moveSkipped)
+	}
+}
+
+func TestResolveDestDir_SuffixPolicy(t *testing.T) {
+	destRoot := t.TempDir()
+	destFS := openLocalFS(t, destRoot)
+	// Occupy "chart" and "chart-1" so the policy must skip to "chart-2".
+	if err := os.MkdirAll(destFS.Join("chart"), 0755); err != nil {
+		t.Fatalf("seeding destination collision: %v", err)
+	}
+	if err := os.MkdirAll(destFS.Join("chart-1"), 0755); err != nil {
+		t.Fatalf("seeding destination collision: %v", err)
+	}
+
+	srcRoot := t.TempDir()
+	dir := quarantineDir{path: filepath.Join(srcRoot, "chart"), srcRoot: srcRoot}
+
+	destDir, status, err := resolveDestDir(destFS, dir, conflictSuffix)
+	if err != nil {
+		t.Fatalf("resolveDestDir: %v", err)
+	}
+	if status != "" {
+		t.Errorf("status = %q, want empty (suffix policy always finds a free name)", status)
+	}
+	if want := destFS.Join("chart-2"); destDir != want {
+		t.Errorf("destDir = %q, want %q", destDir, want)
+	}
+}
+
+func TestResolveDestDir_PreserveTreePolicy(t *testing.T) {
+	destRoot := t.TempDir()
+	destFS := openLocalFS(t, destRoot)
+	// Occupy the flat "chart" name so resolveDestDir falls through into
+	// the preserveTree branch instead of returning the uncontested flat
+	// candidate.
+	if err := os.MkdirAll(destFS.Join("chart"), 0755); err != nil {
+		t.Fatalf("seeding destination collision: %v", err)
+	}
+
+	srcRoot := t.TempDir()
+	dir := quarantineDir{path: filepath.Join(srcRoot, "series", "chart"), srcRoot: srcRoot}
+
+	destDir, status, err := resolveDestDir(destFS, dir, conflictPreserveTree)
+	if err != nil {
+		t.Fatalf("resolveDestDir: %v", err)
+	}
+	if status != "" {
+		t.Errorf("status = %q, want empty (the series/chart tree path itself is free)", status)
+	}
+	if want := destFS.Join(filepath.Join("series", "chart")); destDir != want {
+		t.Errorf("destDir = %q, want %q", destDir, want)
+	}
+
+	// Seeding the tree path itself now makes even preserveTree collide.
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		t.Fatalf("seeding tree-path collision: %v", err)
+	}
+	_, status, err = resolveDestDir(destFS, dir, conflictPreserveTree)
+	if err != nil {
+		t.Fatalf("resolveDestDir: %v", err)
+	}
+	if status != moveConflict {
+		t.Errorf("status = %q, want %q once the tree path itself is occupied", status, moveConflict)
+	}
+}
+
+// TestMoveDirectory_ConcurrentSameBasenameResolvesConflict races two
+// workers moving different source directories that share a destination
+// basename, the way chunk1-3's worker pool does. Before destMu serialized
+// resolveDestDir together with the directory creation that follows it,
+// both workers could pass the collision check before either created the
+// destination, so the loser's MkdirAll/Rename failed with a raw OS error
+// instead of a moveConflict outcome.
+func TestMoveDirectory_ConcurrentSameBasenameResolvesConflict(t *testing.T) {
+	destRoot := t.TempDir()
+	destFS := openLocalFS(t, destRoot)
+
+	var destMu sync.Mutex
+	var wg sync.WaitGroup
+	outcomes := make([]moveOutcome, 2)
+	errs := make([]error, 2)
+
+	for i := 0; i < 2; i++ {
+		i := i
+		srcRoot := t.TempDir()
+		srcDir := filepath.Join(srcRoot, "chart")
+		if err := os.MkdirAll(srcDir, 0755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dir := quarantineDir{fs: destFS, path: srcDir, srcRoot: srcRoot}
+			outcomes[i], errs[i] = moveDirectory(destFS, dir, conflictError, false, nil, &destMu)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("worker %d: moveDirectory: %v", i, err)
+		}
+	}
+
+	var moved, conflicts int
+	for _, outcome := range outcomes {
+		switch outcome.status {
+		case moveMoved:
+			moved++
+		case moveConflict:
+			conflicts++
+		default:
+			t.Errorf("outcome status = %q, want %q or %q", outcome.status, moveMoved, moveConflict)
+		}
+	}
+	if moved != 1 || conflicts != 1 {
+		t.Errorf("got %d moved and %d conflicts, want exactly one of each", moved, conflicts)
+	}
+}
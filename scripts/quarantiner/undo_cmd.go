@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shuymn/bmm/internal/vfs"
+)
+
+// runUndo handles the `undo` subcommand: bmm undo -journal path. It
+// replays a journal written by a previous quarantine run in reverse,
+// moving each directory back to where it came from.
+func runUndo(args []string) {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	var journalPath string
+	fs.StringVar(&journalPath, "journal", "", "journal file written by a previous quarantine run")
+	fs.Parse(args)
+
+	if journalPath == "" {
+		fmt.Println("usage: bmm undo -journal path")
+		return
+	}
+
+	entries, err := readJournal(journalPath)
+	if err != nil {
+		fmt.Printf("Error reading journal: %s", err)
+		return
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if err := undoMove(entry); err != nil {
+			fmt.Printf("Error undoing move of %s: %s\n", entry.Src, err)
+			return
+		}
+		fmt.Printf("Restored %s -> %s\n", entry.Dest, entry.Src)
+	}
+}
+
+func readJournal(path string) ([]journalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("Error parsing journal line: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error scanning file: %w", err)
+	}
+	return entries, nil
+}
+
+// undoMove moves entry's dest back to entry.Src, first verifying dest
+// still exists and src is still absent, so an already-undone or
+// manually-resolved entry doesn't clobber anything. Mirroring
+// moveDirectory, it uses a native rename when src and dest share a
+// backend, and falls back to copy-then-delete across srcFS and destFS
+// otherwise.
+func undoMove(entry journalEntry) error {
+	srcFS, err := vfs.Open(entry.SrcBackend)
+	if err != nil {
+		return err
+	}
+	destFS, err := vfs.Open(entry.DestBackend)
+	if err != nil {
+		return err
+	}
+
+	if _, err := destFS.Stat(entry.Dest); err != nil {
+		return fmt.Errorf("destination no longer exists: %w", err)
+	}
+	if _, err := srcFS.Stat(entry.Src); err == nil {
+		return fmt.Errorf("original location %s is occupied, refusing to overwrite", entry.Src)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("Error checking original location: %w", err)
+	}
+
+	if srcFS.Type() == destFS.Type() {
+		if err := destFS.Rename(entry.Dest, entry.Src); err != nil {
+			return fmt.Errorf("Error restoring directory: %w", err)
+		}
+		return nil
+	}
+
+	if err := copyDir(destFS, entry.Dest, srcFS, entry.Src); err != nil {
+		return fmt.Errorf("Error restoring directory: %w", err)
+	}
+	if err := destFS.RemoveAll(entry.Dest); err != nil {
+		return fmt.Errorf("Error deleting directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/shuymn/bmm/internal/vfs"
+)
+
+// fakeRemoteFS wraps a local Filesystem but reports a different Type, so
+// tests can exercise moveDirectory's/undoMove's cross-backend (copy then
+// delete) path without a real second backend.
+type fakeRemoteFS struct {
+	vfs.Filesystem
+}
+
+func (fakeRemoteFS) Type() string { return "fakeremote" }
+
+func init() {
+	vfs.Register("fakeremote", func(u *url.URL) (vfs.Filesystem, error) {
+		inner, err := vfs.Open((&url.URL{Scheme: "file", Path: u.Path}).String())
+		if err != nil {
+			return nil, err
+		}
+		return fakeRemoteFS{inner}, nil
+	})
+}
+
+// TestJournalUndoRoundTrip_CrossBackend pins down that a move between two
+// different backends can be undone: the journal must record each side's
+// own backend URI, and undoMove must use srcFS for the restored path and
+// destFS for the one being removed, not a single shared Filesystem.
+func TestJournalUndoRoundTrip_CrossBackend(t *testing.T) {
+	srcRoot := t.TempDir()
+	destRoot := t.TempDir()
+
+	srcDir := filepath.Join(srcRoot, "chart")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "main.bms"), []byte("#TITLE test\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	srcFS, err := vfs.Open("file://" + srcRoot)
+	if err != nil {
+		t.Fatalf("opening src backend: %v", err)
+	}
+	destFS, err := vfs.Open("fakeremote://" + destRoot)
+	if err != nil {
+		t.Fatalf("opening dest backend: %v", err)
+	}
+
+	journalPath := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := openJournal(journalPath)
+	if err != nil {
+		t.Fatalf("openJournal: %v", err)
+	}
+
+	dir := quarantineDir{fs: srcFS, path: srcDir, srcRoot: srcRoot}
+	var destMu sync.Mutex
+	outcome, err := moveDirectory(destFS, dir, conflictError, false, j, &destMu)
+	if err != nil {
+		t.Fatalf("moveDirectory: %v", err)
+	}
+	if outcome.status != moveMoved {
+		t.Fatalf("moveDirectory status = %s, want %s", outcome.status, moveMoved)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatalf("closing journal: %v", err)
+	}
+
+	if _, err := os.Stat(srcDir); !os.IsNotExist(err) {
+		t.Fatalf("src dir still present after move: %v", err)
+	}
+
+	entries, err := readJournal(journalPath)
+	if err != nil {
+		t.Fatalf("readJournal: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("len(entries) = %d, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry.SrcBackend != srcFS.URI() {
+		t.Errorf("entry.SrcBackend = %q, want %q", entry.SrcBackend, srcFS.URI())
+	}
+	if entry.DestBackend != destFS.URI() {
+		t.Errorf("entry.DestBackend = %q, want %q", entry.DestBackend, destFS.URI())
+	}
+
+	if err := undoMove(entry); err != nil {
+		t.Fatalf("undoMove: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(srcDir, "main.bms")); err != nil {
+		t.Errorf("restored file missing: %v", err)
+	}
+	if _, err := os.Stat(entry.Dest); !os.IsNotExist(err) {
+		t.Errorf("dest dir still present after undo: %v", err)
+	}
+}
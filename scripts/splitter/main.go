@@ -1,31 +1,27 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"crypto/sha256"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strings"
 	"sync"
 
 	_ "embed"
 
 	"github.com/google/uuid"
-	"github.com/saintfish/chardet"
 	"golang.org/x/exp/slices"
 	"golang.org/x/sync/errgroup"
-	"golang.org/x/text/encoding/japanese"
-	"golang.org/x/text/encoding/korean"
-	"golang.org/x/text/encoding/unicode/utf32"
-	"golang.org/x/text/transform"
+
+	"github.com/shuymn/bmm/internal/chart"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -36,33 +32,58 @@ const (
 
 	maxConcurrency = 10
 	upsertBatch    = 1000
-	bufferSize     = 128 * 1024
-)
-
-var (
-	reBMSTitle     = regexp.MustCompile(`(?i)^#title[\s\t]*(.*?)(?:\r\n|\r|\n|$)`)
-	reBMSSubtitle  = regexp.MustCompile(`(?i)^#subtitle[\s\t]*(.*?)(?:\r\n|\r|\n|$)`)
-	reBMSArtist    = regexp.MustCompile(`(?i)^#artist[\s\t]*(.*?)(?:\r\n|\r|\n|$)`)
-	reBMSSubartist = regexp.MustCompile(`(?i)^#subartist[\s\t]*(.*?)(?:\r\n|\r|\n|$)`)
 )
 
 //go:embed schema.sql
 var schema []byte
 
-func main() {
+// openDB opens the shared sqlite database and ensures its schema exists,
+// for use by both the indexing flow and the tables/list subcommands.
+func openDB(ctx context.Context) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	defer db.Close()
+	if _, err := db.ExecContext(ctx, string(schema)); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
 
-	ctx := context.Background()
-	if _, err = db.ExecContext(ctx, string(schema)); err != nil {
-		fmt.Println(err)
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "tables":
+			runTables(os.Args[2:])
+			return
+		case "list":
+			runList(os.Args[2:])
+			return
+		}
+	}
+
+	var srcs, exts stringSlice
+	var watch bool
+	flag.Var(&srcs, "src", "source directory to scan (repeatable)")
+	flag.Var(&exts, "ext", "chart file extension to scan, e.g. .bms (repeatable)")
+	flag.BoolVar(&watch, "watch", false, "after the initial scan, keep running and incrementally re-index changed files")
+	flag.Parse()
+
+	config, err := loadConfig(srcs, exts)
+	if err != nil {
+		fmt.Printf("Error loading config: %s", err)
 		return
 	}
 
-	cli, err := NewCLI(ctx, db)
+	ctx := context.Background()
+	db, err := openDB(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	cli, err := NewCLI(ctx, db, config)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -73,6 +94,15 @@ func main() {
 		return
 	}
 	fmt.Println("done")
+
+	if !watch {
+		return
+	}
+
+	if err := cli.Watch(ctx); err != nil {
+		fmt.Println(err)
+		return
+	}
 }
 
 type Config struct {
@@ -80,7 +110,25 @@ type Config struct {
 	Extensions []string `json:"extensions"`
 }
 
-func NewConfig() (*Config, error) {
+// loadConfig builds a Config from the given flags, falling back to
+// config.json only when none of them were set.
+func loadConfig(srcs, exts stringSlice) (config *Config, err error) {
+	if len(srcs) == 0 && len(exts) == 0 {
+		config, err = loadConfigFile()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		config = &Config{Sources: srcs, Extensions: exts}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func loadConfigFile() (config *Config, err error) {
 	file, err := os.Open(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("Error opening file: %w", err)
@@ -92,15 +140,11 @@ func NewConfig() (*Config, error) {
 		return nil, fmt.Errorf("Error reading file: %w", err)
 	}
 
-	var config Config
 	if err := json.Unmarshal(b, &config); err != nil {
 		return nil, fmt.Errorf("Error parsing JSON: %w", err)
 	}
 
-	if err := config.Validate(); err != nil {
-		return nil, err
-	}
-	return &config, nil
+	return config, nil
 }
 
 func (c *Config) Validate() error {
@@ -123,22 +167,30 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// stringSlice is a flag.Value that collects repeated occurrences of a
+// flag into a slice, e.g. -src a -src b.
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
 type CLI struct {
 	config      *Config
 	db          *sql.DB
-	bmsList     []*BMS
+	bmsList     []*chart.Chart
 	songs       map[string]string
 	sliceMutex  sync.Mutex
 	mapMutex    sync.Mutex
 	upsertMutex sync.Mutex
 }
 
-func NewCLI(ctx context.Context, db *sql.DB) (*CLI, error) {
-	config, err := NewConfig()
-	if err != nil {
-		return nil, err
-	}
-
+func NewCLI(ctx context.Context, db *sql.DB, config *Config) (*CLI, error) {
 	return &CLI{
 		config: config,
 		db:     db,
@@ -152,11 +204,19 @@ func (c *CLI) Run(ctx context.Context) error {
 		return err
 	}
 
-	var eg errgroup.Group
-	semaphore := make(chan struct{}, maxConcurrency)
-	c.bmsList = make([]*BMS, 0, upsertBatch)
+	c.bmsList = make([]*chart.Chart, 0, upsertBatch)
 	for _, root := range c.config.Sources {
-		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		watermark, err := c.loadWatermark(ctx, root)
+		if err != nil {
+			return err
+		}
+
+		var maxMTime int64
+		var maxMu sync.Mutex
+
+		var eg errgroup.Group
+		semaphore := make(chan struct{}, maxConcurrency)
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
@@ -167,6 +227,20 @@ func (c *CLI) Run(ctx context.Context) error {
 				return nil
 			}
 
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			mtime := info.ModTime().UnixNano()
+			maxMu.Lock()
+			if mtime > maxMTime {
+				maxMTime = mtime
+			}
+			maxMu.Unlock()
+			if mtime <= watermark {
+				return nil
+			}
+
 			semaphore <- struct{}{}
 			eg.Go(func() error {
 				defer func() { <-semaphore }()
@@ -177,8 +251,41 @@ func (c *CLI) Run(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
+		if err := eg.Wait(); err != nil {
+			return err
+		}
+		if err := c.UpsertPattern(ctx); err != nil {
+			return err
+		}
+		if maxMTime > watermark {
+			if err := c.saveWatermark(ctx, root, maxMTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *CLI) loadWatermark(ctx context.Context, root string) (int64, error) {
+	var watermark int64
+	err := c.db.QueryRowContext(ctx, "SELECT watermark FROM scan_state WHERE root = ?", root).Scan(&watermark)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Error querying database: %w", err)
+	}
+	return watermark, nil
+}
+
+func (c *CLI) saveWatermark(ctx context.Context, root string, watermark int64) error {
+	_, err := c.db.ExecContext(ctx, `
+INSERT INTO scan_state (root, watermark) VALUES (?, ?)
+ON CONFLICT(root) DO UPDATE SET watermark = excluded.watermark;`, root, watermark)
+	if err != nil {
+		return fmt.Errorf("Error executing statement: %w", err)
 	}
-	return eg.Wait()
+	return nil
 }
 
 func (c *CLI) ListSongs(ctx context.Context) (map[string]string, error) {
@@ -200,7 +307,7 @@ func (c *CLI) ListSongs(ctx context.Context) (map[string]string, error) {
 }
 
 func (c *CLI) UpsertPatterns(ctx context.Context, path string) error {
-	bms, err := ParseBMS(path)
+	bms, err := chart.Parse(path)
 	if err != nil {
 		return err
 	}
@@ -227,9 +334,10 @@ func (c *CLI) UpsertPattern(ctx context.Context) error {
 	}()
 
 	stmt1, err := tx.PrepareContext(ctx, `
-INSERT INTO patterns (hash, title, subtitle, artist, subartist, path, song_id)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+INSERT INTO patterns (hash, md5, title, subtitle, artist, subartist, path, song_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(hash) DO UPDATE SET
+	md5 = excluded.md5,
 	title = excluded.title,
 	subtitle = excluded.subtitle,
 	artist = excluded.artist,
@@ -264,7 +372,7 @@ ON CONFLICT(hash) DO UPDATE SET
 			c.songs[path] = songID
 			c.mapMutex.Unlock()
 		}
-		_, err = stmt1.ExecContext(ctx, bms.Hash, bms.Title, bms.Subtitle, bms.Artist, bms.Subartist, bms.Path, songID)
+		_, err = stmt1.ExecContext(ctx, bms.Hash, bms.MD5, bms.Title, bms.Subtitle, bms.Artist, bms.Subartist, bms.Path, songID)
 		if err != nil {
 			return fmt.Errorf("Error executing statement: %w", err)
 		}
@@ -279,7 +387,7 @@ ON CONFLICT(hash) DO UPDATE SET
 	return nil
 }
 
-func (c *CLI) AppendBMSList(bms *BMS) {
+func (c *CLI) AppendBMSList(bms *chart.Chart) {
 	c.sliceMutex.Lock()
 	c.bmsList = append(c.bmsList, bms)
 	c.sliceMutex.Unlock()
@@ -291,81 +399,6 @@ func (c *CLI) ResetBMSList() {
 	c.sliceMutex.Unlock()
 }
 
-type BMS struct {
-	Path      string
-	Hash      string
-	Title     string
-	Subtitle  string
-	Artist    string
-	Subartist string
-}
-
-func ParseBMS(path string) (*BMS, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("Error opening file: %w", err)
-	}
-	defer file.Close()
-
-	b, err := io.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("Error reading file: %w", err)
-	}
-
-	b, err = convertToUTF8(b)
-	if err != nil {
-		return nil, fmt.Errorf("Error file: %s: %w", path, err)
-	}
-
-	bms := &BMS{
-		Path: path,
-		Hash: calculateHash(b),
-	}
-
-	scanner := bufio.NewScanner(bytes.NewReader(b))
-	buf := make([]byte, 0, bufferSize)
-	scanner.Buffer(buf, bufferSize)
-	for scanner.Scan() {
-		if bms.Artist != "" && bms.Subartist != "" && bms.Title != "" && bms.Subtitle != "" {
-			break
-		}
-		line := scanner.Text()
-		match := reBMSTitle.FindStringSubmatch(line)
-		if len(match) > 1 {
-			if bms.Title == "" {
-				bms.Title = match[1]
-			}
-			continue
-		}
-		match = reBMSSubtitle.FindStringSubmatch(line)
-		if len(match) > 1 {
-			if bms.Subtitle == "" {
-				bms.Subtitle = match[1]
-			}
-			continue
-		}
-		match = reBMSArtist.FindStringSubmatch(line)
-		if len(match) > 1 {
-			if bms.Artist == "" {
-				bms.Artist = match[1]
-			}
-			continue
-		}
-		match = reBMSSubartist.FindStringSubmatch(line)
-		if len(match) > 1 {
-			if bms.Subartist == "" {
-				bms.Subartist = match[1]
-			}
-			continue
-		}
-	}
-	if err := scanner.Err(); err != nil && err != io.EOF {
-		return nil, fmt.Errorf("Error scanning file: %w", err)
-	}
-
-	return bms, nil
-}
-
 func checkDirectoryExistance(path string) error {
 	if _, err := os.Stat(path); err != nil {
 		if os.IsNotExist(err) {
@@ -375,40 +408,3 @@ func checkDirectoryExistance(path string) error {
 	}
 	return nil
 }
-
-func calculateHash(input []byte) string {
-	hash := sha256.Sum256(input)
-	return fmt.Sprintf("%x", hash)
-}
-
-func convertToUTF8(input []byte) ([]byte, error) {
-	d := chardet.NewTextDetector()
-	res, err := d.DetectBest(input)
-	if err != nil {
-		return nil, fmt.Errorf("Error detecting encoding: %w", err)
-	}
-
-	dec := japanese.ShiftJIS.NewDecoder()
-	if res.Confidence == 100 {
-		switch res.Charset {
-		case "UTF-8":
-			// No conversion needed
-			return input, nil
-		case "UTF-32BE":
-			dec = utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM).NewDecoder()
-		case "UTF-32LE":
-			dec = utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM).NewDecoder()
-		case "EUC-KR":
-			dec = korean.EUCKR.NewDecoder()
-		default:
-			if res.Charset != "Shift_JIS" {
-				fmt.Println("Unknown encoding:", res.Charset)
-			}
-		}
-	}
-	output, err := io.ReadAll(transform.NewReader(bytes.NewReader(input), dec))
-	if err != nil {
-		return nil, fmt.Errorf("Error converting to UTF-8: %w", err)
-	}
-	return output, nil
-}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/shuymn/bmm/internal/tables"
+)
+
+// runTables handles the `tables` subcommand, e.g. `tables add <url>`.
+func runTables(args []string) {
+	if len(args) < 1 {
+		fmt.Println("usage: bmm tables add <url>")
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		runTablesAdd(args[1:])
+	default:
+		fmt.Printf("unknown tables subcommand: %s\n", args[0])
+	}
+}
+
+func runTablesAdd(args []string) {
+	fs := flag.NewFlagSet("tables add", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fmt.Println("usage: bmm tables add <url>")
+		return
+	}
+
+	ctx := context.Background()
+	db, err := openDB(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer db.Close()
+
+	headerURL := fs.Arg(0)
+	header, entries, err := tables.Fetch(ctx, nil, headerURL)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := upsertTable(ctx, db, headerURL, header, entries); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("added table %q (%s): %d entries\n", header.Name, header.Symbol, len(entries))
+}
+
+// upsertTable stores header and entries under headerURL's identity,
+// replacing any entries from a previous fetch of the same table.
+func upsertTable(ctx context.Context, db *sql.DB, headerURL string, header *tables.Header, entries []tables.Entry) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("Error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO difficulty_tables (name, symbol, source_url, fetched_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(source_url) DO UPDATE SET
+	name = excluded.name,
+	symbol = excluded.symbol,
+	fetched_at = excluded.fetched_at;`,
+		header.Name, header.Symbol, headerURL, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("Error executing statement: %w", err)
+	}
+
+	var tableID int64
+	if err = tx.QueryRowContext(ctx, "SELECT id FROM difficulty_tables WHERE source_url = ?", headerURL).Scan(&tableID); err != nil {
+		return fmt.Errorf("Error querying database: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM difficulty_entries WHERE table_id = ?", tableID); err != nil {
+		return fmt.Errorf("Error executing statement: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO difficulty_entries (table_id, md5, level, title, url) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("Error preparing statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		if _, err = stmt.ExecContext(ctx, tableID, e.MD5, e.Level, e.Title, e.URL); err != nil {
+			return fmt.Errorf("Error executing statement: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("Error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// runList handles the `list` subcommand, e.g. `list --table Insane --level 15`.
+func runList(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	table := fs.String("table", "", "difficulty table symbol to filter by")
+	level := fs.String("level", "", "difficulty level to filter by")
+	fs.Parse(args)
+
+	if *table == "" || *level == "" {
+		fmt.Println("usage: bmm list --table <symbol> --level <level>")
+		return
+	}
+
+	ctx := context.Background()
+	db, err := openDB(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer db.Close()
+
+	if err := listPatterns(ctx, db, *table, *level); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func listPatterns(ctx context.Context, db *sql.DB, symbol, level string) error {
+	rows, err := db.QueryContext(ctx, `
+SELECT DISTINCT patterns.title, patterns.artist, patterns.path
+FROM pattern_levels
+JOIN patterns ON patterns.hash = pattern_levels.pattern_hash
+WHERE pattern_levels.symbol = ? AND pattern_levels.level = ?
+ORDER BY patterns.title;`, symbol, level)
+	if err != nil {
+		return fmt.Errorf("Error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var title, artist, path string
+		if err := rows.Scan(&title, &artist, &path); err != nil {
+			return fmt.Errorf("Error scanning row: %w", err)
+		}
+		fmt.Printf("%s - %s\n  %s\n", title, artist, path)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestDB opens an in-memory database with the real schema applied, the
+// same way openDB does for the file-backed one.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.ExecContext(context.Background(), string(schema)); err != nil {
+		t.Fatalf("applying schema: %v", err)
+	}
+	return db
+}
+
+// TestListPatterns_JoinsOnBMSMD5 pins down that pattern_levels joins
+// patterns to difficulty_entries via the bmsmd5 (patterns.md5), the
+// identity difficulty tables actually publish, rather than this
+// database's own sha256 pattern hash.
+func TestListPatterns_JoinsOnBMSMD5(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	const bmsmd5 = "0123456789abcdef0123456789abcdef"
+	if _, err := db.ExecContext(ctx, "INSERT INTO songs (id, path) VALUES ('song1', '/charts/song1')"); err != nil {
+		t.Fatalf("seeding songs: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+INSERT INTO patterns (hash, md5, title, artist, path, song_id)
+VALUES ('sha256-of-file', ?, 'Test Title', 'Test Artist', '/charts/song1/test.bms', 'song1')`, bmsmd5); err != nil {
+		t.Fatalf("seeding patterns: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+INSERT INTO difficulty_tables (id, name, symbol, source_url, fetched_at)
+VALUES (1, 'Insane BMS', '★', 'https://example.invalid/header.json', 0)`); err != nil {
+		t.Fatalf("seeding difficulty_tables: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+INSERT INTO difficulty_entries (table_id, md5, level, title, url)
+VALUES (1, ?, '15', 'Test Title', 'https://example.invalid/song1')`, bmsmd5); err != nil {
+		t.Fatalf("seeding difficulty_entries: %v", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+SELECT DISTINCT patterns.title, patterns.artist, patterns.path
+FROM pattern_levels
+JOIN patterns ON patterns.hash = pattern_levels.pattern_hash
+WHERE pattern_levels.symbol = ? AND pattern_levels.level = ?
+ORDER BY patterns.title;`, "★", "15")
+	if err != nil {
+		t.Fatalf("querying pattern_levels: %v", err)
+	}
+	defer rows.Close()
+
+	var titles []string
+	for rows.Next() {
+		var title, artist, path string
+		if err := rows.Scan(&title, &artist, &path); err != nil {
+			t.Fatalf("scanning row: %v", err)
+		}
+		titles = append(titles, title)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("rows.Err: %v", err)
+	}
+	if len(titles) != 1 || titles[0] != "Test Title" {
+		t.Errorf("pattern_levels join returned %v, want [\"Test Title\"]", titles)
+	}
+}
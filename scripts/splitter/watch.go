@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/google/uuid"
+
+	"github.com/shuymn/bmm/internal/chart"
+)
+
+// debounceWindow coalesces bursts of filesystem events for the same path
+// (editors commonly emit several writes per save) before it is re-parsed.
+const debounceWindow = 500 * time.Millisecond
+
+// Watch runs after the initial Run scan and keeps the database in sync with
+// config.Sources by monitoring them with fsnotify. It blocks until ctx is
+// canceled or the watcher is closed.
+func (c *CLI) Watch(ctx context.Context) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Error creating watcher: %w", err)
+	}
+	defer fsw.Close()
+
+	w := &watcher{
+		cli:      c,
+		fsw:      fsw,
+		timers:   make(map[string]*time.Timer),
+		pathHash: make(map[string]string),
+		removed:  make(map[string]string),
+	}
+
+	if err := w.loadPathHashes(ctx); err != nil {
+		return err
+	}
+
+	for _, root := range c.config.Sources {
+		if err := w.addRecursive(root); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("watching for changes...")
+	for {
+		select {
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			w.handleEvent(ctx, event)
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Println("watch error:", err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// watcher tracks in-flight debounces and the path<->hash mapping needed to
+// tell a rename (remove immediately followed by a create of the same
+// content) apart from an unrelated delete and create.
+type watcher struct {
+	cli *CLI
+	fsw *fsnotify.Watcher
+
+	mu       sync.Mutex
+	timers   map[string]*time.Timer
+	pathHash map[string]string
+	removed  map[string]string // content hash -> path, pending deletion
+}
+
+func (w *watcher) loadPathHashes(ctx context.Context) error {
+	rows, err := w.cli.db.QueryContext(ctx, "SELECT hash, path FROM patterns")
+	if err != nil {
+		return fmt.Errorf("Error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash, path string
+		if err := rows.Scan(&hash, &path); err != nil {
+			return fmt.Errorf("Error scanning row: %w", err)
+		}
+		w.pathHash[path] = hash
+	}
+	return rows.Err()
+}
+
+func (w *watcher) addRecursive(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return w.fsw.Add(path)
+	})
+}
+
+func (w *watcher) handleEvent(ctx context.Context, event fsnotify.Event) {
+	if !supportedExt(w.cli.config.Extensions, event.Name) {
+		if event.Op.Has(fsnotify.Create) {
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				if err := w.addRecursive(event.Name); err != nil {
+					fmt.Println("Error watching new directory:", err)
+				}
+			}
+		}
+		return
+	}
+
+	w.debounce(event.Name, func() {
+		var err error
+		switch {
+		case event.Op.Has(fsnotify.Create), event.Op.Has(fsnotify.Write):
+			err = w.upsert(ctx, event.Name)
+		case event.Op.Has(fsnotify.Remove), event.Op.Has(fsnotify.Rename):
+			err = w.remove(ctx, event.Name)
+		}
+		if err != nil {
+			fmt.Printf("Error handling %s: %s\n", event, err)
+		}
+	})
+}
+
+func (w *watcher) debounce(path string, fn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t, ok := w.timers[path]; ok {
+		t.Stop()
+	}
+	w.timers[path] = time.AfterFunc(debounceWindow, func() {
+		w.mu.Lock()
+		delete(w.timers, path)
+		w.mu.Unlock()
+		fn()
+	})
+}
+
+func (w *watcher) upsert(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			// Removed again before the debounced write fired; nothing to index.
+			return nil
+		}
+		return fmt.Errorf("Error checking file: %w", err)
+	}
+
+	bms, err := chart.Parse(path)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	oldPath, isRename := w.removed[bms.Hash]
+	if isRename {
+		delete(w.removed, bms.Hash)
+	}
+	oldHash, hadOldHash := w.pathHash[path]
+	w.pathHash[path] = bms.Hash
+	w.mu.Unlock()
+
+	if isRename && oldPath != path {
+		return w.cli.RenamePattern(ctx, bms.Hash, path)
+	}
+	if err := w.cli.UpsertOne(ctx, bms); err != nil {
+		return err
+	}
+	if hadOldHash && oldHash != bms.Hash {
+		// The file's content (and so its hash) changed in place: ON
+		// CONFLICT(hash) above inserted a new row rather than updating the
+		// one from the previous index, so the stale hash-keyed row must be
+		// cleaned up explicitly, same as a real delete would be.
+		return w.cli.DeletePattern(ctx, oldHash, path)
+	}
+	return nil
+}
+
+func (w *watcher) remove(ctx context.Context, path string) error {
+	w.mu.Lock()
+	hash, ok := w.pathHash[path]
+	if ok {
+		delete(w.pathHash, path)
+		w.removed[hash] = path
+	}
+	w.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	// Give a matching create event (the other half of a rename) a chance to
+	// arrive before treating this as a real delete.
+	time.AfterFunc(debounceWindow, func() {
+		w.mu.Lock()
+		stillRemoved, pending := w.removed[hash]
+		if pending {
+			delete(w.removed, hash)
+		}
+		w.mu.Unlock()
+		if !pending {
+			return
+		}
+		if err := w.cli.DeletePattern(ctx, hash, stillRemoved); err != nil {
+			fmt.Println("Error deleting pattern:", err)
+		}
+	})
+	return nil
+}
+
+func supportedExt(extensions []string, path string) bool {
+	ext := filepath.Ext(path)
+	for _, e := range extensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// UpsertOne indexes a single chart outside of the batched Run path, for use
+// by Watch where events must be reflected immediately.
+func (c *CLI) UpsertOne(ctx context.Context, bms *chart.Chart) error {
+	c.upsertMutex.Lock()
+	defer c.upsertMutex.Unlock()
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	songID, err := c.resolveSongID(ctx, tx, filepath.Dir(bms.Path))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO patterns (hash, md5, title, subtitle, artist, subartist, path, song_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(hash) DO UPDATE SET
+	md5 = excluded.md5,
+	title = excluded.title,
+	subtitle = excluded.subtitle,
+	artist = excluded.artist,
+	subartist = excluded.subartist,
+	path = excluded.path,
+	song_id = excluded.song_id;`,
+		bms.Hash, bms.MD5, bms.Title, bms.Subtitle, bms.Artist, bms.Subartist, bms.Path, songID)
+	if err != nil {
+		return fmt.Errorf("Error executing statement: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (c *CLI) resolveSongID(ctx context.Context, tx *sql.Tx, path string) (string, error) {
+	c.mapMutex.Lock()
+	songID, ok := c.songs[path]
+	c.mapMutex.Unlock()
+	if ok {
+		return songID, nil
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("Error generating UUID: %w", err)
+	}
+	songID = id.String()
+	if _, err := tx.ExecContext(ctx, "INSERT INTO songs (id, path) VALUES (?, ?)", songID, path); err != nil {
+		return "", fmt.Errorf("Error executing statement: %w", err)
+	}
+
+	c.mapMutex.Lock()
+	c.songs[path] = songID
+	c.mapMutex.Unlock()
+	return songID, nil
+}
+
+// RenamePattern repoints the pattern previously indexed under hash at its
+// new path, preserving the existing row instead of inserting a duplicate.
+func (c *CLI) RenamePattern(ctx context.Context, hash, newPath string) error {
+	_, err := c.db.ExecContext(ctx, "UPDATE patterns SET path = ? WHERE hash = ?", newPath, hash)
+	if err != nil {
+		return fmt.Errorf("Error executing statement: %w", err)
+	}
+	return nil
+}
+
+// DeletePattern removes the pattern row for hash and, if that was the last
+// pattern under its song, the now-orphaned song row too.
+func (c *CLI) DeletePattern(ctx context.Context, hash, path string) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var songID string
+	err = tx.QueryRowContext(ctx, "SELECT song_id FROM patterns WHERE hash = ?", hash).Scan(&songID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tx.Commit()
+	}
+	if err != nil {
+		return fmt.Errorf("Error querying database: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM patterns WHERE hash = ?", hash); err != nil {
+		return fmt.Errorf("Error executing statement: %w", err)
+	}
+
+	var remaining int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM patterns WHERE song_id = ?", songID).Scan(&remaining); err != nil {
+		return fmt.Errorf("Error querying database: %w", err)
+	}
+	if remaining == 0 {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM songs WHERE id = ?", songID); err != nil {
+			return fmt.Errorf("Error executing statement: %w", err)
+		}
+		c.mapMutex.Lock()
+		delete(c.songs, filepath.Dir(path))
+		c.mapMutex.Unlock()
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("Error committing transaction: %w", err)
+	}
+	return nil
+}
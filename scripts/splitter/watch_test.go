@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWatcherUpsert_CleansUpStaleHashRow pins down that editing an
+// already-indexed file's content (which changes its hash) doesn't leave the
+// previous hash-keyed row behind: the old row must be removed once the new
+// one is indexed.
+func TestWatcherUpsert_CleansUpStaleHashRow(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	cli := &CLI{db: db, songs: make(map[string]string)}
+	w := &watcher{
+		cli:      cli,
+		pathHash: make(map[string]string),
+		removed:  make(map[string]string),
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "song.bms")
+	if err := os.WriteFile(path, []byte("#TITLE v1\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := w.upsert(ctx, path); err != nil {
+		t.Fatalf("first upsert: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("#TITLE v2\n"), 0o644); err != nil {
+		t.Fatalf("rewrite file: %v", err)
+	}
+	if err := w.upsert(ctx, path); err != nil {
+		t.Fatalf("second upsert: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM patterns WHERE path = ?", path).Scan(&count); err != nil {
+		t.Fatalf("counting patterns: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("patterns rows for %s = %d, want 1 (stale hash-keyed row left behind)", path, count)
+	}
+}
@@ -1,30 +1,48 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"crypto/md5"
+	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
 	"runtime"
 	"strings"
+	"time"
 
+	"github.com/bmatcuk/doublestar/v4"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/text/encoding/japanese"
+
+	"github.com/shuymn/bmm/internal/chart"
+	"github.com/shuymn/bmm/internal/lr2ir"
+
+	_ "github.com/mattn/go-sqlite3"
 )
 
 const (
 	extWAV = ".wav"
 	extOGG = ".ogg"
 
-	lr2irBaseURL = "http://www.dream-pro.info/~lavalse/LR2IR/search.cgi"
+	cacheDBPath     = "./lr2ir.db"
+	cacheTTL        = 7 * 24 * time.Hour
+	lookupWorkers   = 4
+	defaultLR2IRQPS = 1.0
 )
 
-var wavRegexp = regexp.MustCompile(`#WAV([0-9A-Za-z]{2})\s(.*)`)
+// missingWAVs describes one chart file with keysounds that couldn't be
+// found on disk, pending an LR2IR lookup to tell a known/ranked chart
+// apart from an obscure or broken one.
+type missingWAVs struct {
+	path    string
+	total   int
+	missing []string
+}
 
 type Config struct {
 	Sources    []string `json:"srcDirs"`
@@ -33,12 +51,38 @@ type Config struct {
 }
 
 func main() {
-	config, err := loadConfig()
+	var srcs, exts, ignores stringSlice
+	var offline bool
+	var qps float64
+	flag.Var(&srcs, "src", "source directory to scan (repeatable)")
+	flag.Var(&exts, "ext", "chart file extension to scan, e.g. .bms (repeatable)")
+	flag.Var(&ignores, "ignore", "doublestar glob pattern to exclude, e.g. **/_sp/** (repeatable)")
+	flag.BoolVar(&offline, "offline", false, "only consult the LR2IR cache; never hit the network")
+	flag.Float64Var(&qps, "qps", defaultLR2IRQPS, "max LR2IR lookups per second")
+	flag.Parse()
+
+	config, err := loadConfig(srcs, exts, ignores)
 	if err != nil {
 		fmt.Printf("Error loading config: %s", err)
 		return
 	}
 
+	db, err := sql.Open("sqlite3", cacheDBPath)
+	if err != nil {
+		fmt.Printf("Error opening cache: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	cache, err := lr2ir.NewSQLiteCache(ctx, db)
+	if err != nil {
+		fmt.Printf("Error preparing cache: %s", err)
+		return
+	}
+	client := lr2ir.NewClient(cache, qps, cacheTTL, offline)
+
+	var found []*missingWAVs
 	for _, src := range config.Sources {
 		err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
 			if err != nil {
@@ -47,19 +91,26 @@ func main() {
 			if d.IsDir() {
 				return nil
 			}
-			idx := containsFunc(config.Ignore, func(s string) bool {
-				return strings.HasPrefix(path, s)
-			})
-			if idx != -1 {
+			ignored, err := matchAny(config.Ignore, path)
+			if err != nil {
+				return err
+			}
+			if ignored {
 				return nil
 			}
-			if idx = contains(config.Extensions, filepath.Ext(path)); idx == -1 {
+			if idx := contains(config.Extensions, filepath.Ext(path)); idx == -1 {
 				return nil
 			}
-			wavs, err := getWAVs(path)
+			keysounds, err := chart.Keysounds(path)
 			if err != nil {
 				return err
 			}
+			wavs := make([]string, 0, len(keysounds))
+			for _, ks := range keysounds {
+				if strings.HasSuffix(ks.File, extWAV) || strings.HasSuffix(ks.File, extOGG) {
+					wavs = append(wavs, ks.File)
+				}
+			}
 			if len(wavs) == 0 {
 				return nil
 			}
@@ -99,27 +150,7 @@ func main() {
 			if len(notFoundWAVs) == 0 {
 				return nil
 			}
-			checksum, err := calculateFileChecksum(path)
-			if err != nil {
-				return fmt.Errorf("Error calculating checksum: %w", err)
-			}
-			u := getIR2IRURL(checksum)
-			fmt.Printf(
-				"Missing WAVs in %s:\n - URL: %s\n - total\t%d\n - missing\t%d (%.1f%%)\n",
-				path,
-				u,
-				len(wavs),
-				len(notFoundWAVs),
-				float64(len(notFoundWAVs))/float64(len(wavs))*100,
-			)
-			for i, wav := range notFoundWAVs {
-				if i > 10 {
-					fmt.Printf("   - ...\n")
-					break
-				}
-				fmt.Printf("   - %s\n", wav)
-			}
-			fmt.Printf("\n")
+			found = append(found, &missingWAVs{path: path, total: len(wavs), missing: notFoundWAVs})
 			return nil
 		})
 		if err != nil {
@@ -127,23 +158,84 @@ func main() {
 			return
 		}
 	}
+
+	if err := reportMissingWAVs(ctx, client, found); err != nil {
+		fmt.Printf("Error reporting missing WAVs: %s", err)
+		return
+	}
 }
 
-func loadConfig() (*Config, error) {
-	file, err := os.Open("config.json")
-	if err != nil {
-		return nil, fmt.Errorf("Error opening file: %w", err)
+// reportMissingWAVs enriches each finding with its LR2IR status, looking
+// lookups up concurrently (bounded by lookupWorkers) since the client's
+// own rate limiter already paces the actual network requests.
+func reportMissingWAVs(ctx context.Context, client *lr2ir.Client, found []*missingWAVs) error {
+	checksums := make([]string, len(found))
+	results := make([]*lr2ir.Result, len(found))
+	lookupErrs := make([]error, len(found))
+
+	var eg errgroup.Group
+	semaphore := make(chan struct{}, lookupWorkers)
+	for i, m := range found {
+		i, m := i, m
+		checksum, err := calculateFileChecksum(m.path)
+		if err != nil {
+			return fmt.Errorf("Error calculating checksum: %w", err)
+		}
+		checksums[i] = checksum
+
+		semaphore <- struct{}{}
+		eg.Go(func() error {
+			defer func() { <-semaphore }()
+			result, err := client.Lookup(ctx, checksum)
+			if err != nil {
+				lookupErrs[i] = err
+				return nil
+			}
+			results[i] = result
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
 	}
-	defer file.Close()
 
-	b, err := io.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("Error reading file: %w", err)
+	for i, m := range found {
+		fmt.Printf(
+			"Missing WAVs in %s:\n - URL: %s\n - total\t%d\n - missing\t%d (%.1f%%)\n",
+			m.path,
+			lr2ir.SearchURL(checksums[i]),
+			m.total,
+			len(m.missing),
+			float64(len(m.missing))/float64(m.total)*100,
+		)
+		switch {
+		case results[i] != nil:
+			fmt.Printf(" - LR2IR: %s / %s (players %d, clears %d)\n", results[i].Title, results[i].Artist, results[i].Players, results[i].Clears)
+		case lookupErrs[i] != nil:
+			fmt.Printf(" - LR2IR: %s\n", lookupErrs[i])
+		}
+		for j, wav := range m.missing {
+			if j > 10 {
+				fmt.Printf("   - ...\n")
+				break
+			}
+			fmt.Printf("   - %s\n", wav)
+		}
+		fmt.Printf("\n")
 	}
+	return nil
+}
 
-	var config *Config
-	if err := json.Unmarshal(b, &config); err != nil {
-		return nil, fmt.Errorf("Error parsing JSON: %w", err)
+// loadConfig builds a Config from the given flags, falling back to
+// config.json only when none of them were set.
+func loadConfig(srcs, exts, ignores stringSlice) (*Config, error) {
+	config := &Config{Sources: srcs, Extensions: exts, Ignore: ignores}
+	if len(srcs) == 0 && len(exts) == 0 && len(ignores) == 0 {
+		fileConfig, err := loadConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		config = fileConfig
 	}
 
 	if len(config.Extensions) == 0 {
@@ -158,7 +250,7 @@ func loadConfig() (*Config, error) {
 		if !filepath.IsAbs(src) {
 			return nil, fmt.Errorf("source directory (%s) must not be a relative path", src)
 		}
-		if err = checkDirectoryExistance(src); err != nil {
+		if err := checkDirectoryExistance(src); err != nil {
 			return nil, err
 		}
 	}
@@ -166,6 +258,26 @@ func loadConfig() (*Config, error) {
 	return config, nil
 }
 
+func loadConfigFile() (*Config, error) {
+	file, err := os.Open("config.json")
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file: %w", err)
+	}
+	defer file.Close()
+
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading file: %w", err)
+	}
+
+	var config *Config
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("Error parsing JSON: %w", err)
+	}
+
+	return config, nil
+}
+
 func checkDirectoryExistance(path string) error {
 	if _, err := os.Stat(path); err != nil {
 		if os.IsNotExist(err) {
@@ -185,47 +297,32 @@ func contains(s []string, target string) int {
 	return -1
 }
 
-func containsFunc(s []string, f func(string) bool) int {
-	for i, v := range s {
-		if f(v) {
-			return i
+// matchAny reports whether path matches any of the doublestar glob
+// patterns, e.g. "**/_sp/**" or "**/*_backup/".
+func matchAny(patterns []string, path string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := doublestar.Match(pattern, path)
+		if err != nil {
+			return false, fmt.Errorf("Error matching ignore pattern %q: %w", pattern, err)
+		}
+		if ok {
+			return true, nil
 		}
 	}
-	return -1
+	return false, nil
 }
 
-func getWAVs(path string) ([]string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("Error opening file: %w", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	wavs := make([]string, 0, 100)
-	for scanner.Scan() {
-		line := scanner.Text()
-		matches := wavRegexp.FindStringSubmatch(line)
-		if len(matches) > 0 {
-			file := matches[2]
-			if strings.HasSuffix(file, extWAV) || strings.HasSuffix(file, extOGG) {
-				wavs = append(wavs, file)
-			}
-		}
-	}
+// stringSlice is a flag.Value that collects repeated occurrences of a
+// flag into a slice, e.g. -src a -src b.
+type stringSlice []string
 
-	return wavs, nil
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
 }
 
-func getIR2IRURL(bmsmd5 string) string {
-	u, err := url.Parse(lr2irBaseURL)
-	if err != nil {
-		panic(err)
-	}
-	q := u.Query()
-	q.Set("bmsmd5", bmsmd5)
-	u.RawQuery = q.Encode()
-	return u.String()
+func (s *stringSlice) Set(v string) error {
+	*s = append(*s, v)
+	return nil
 }
 
 func calculateFileChecksum(path string) (string, error) {